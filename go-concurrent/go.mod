@@ -1,3 +1,7 @@
 module monkeylang
 
 go 1.20
+
+require monkey v0.0.0
+
+replace monkey => ../go-devries
@@ -0,0 +1,95 @@
+package lexer
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultBatchSize = 64
+
+// batchPool holds batches an Iterator has finished with, so that the lexers
+// can reuse them.
+var batchPool sync.Pool
+
+func newBatch(size int) []Token {
+	if b, ok := batchPool.Get().(*[]Token); ok && cap(*b) >= size {
+		return (*b)[:0]
+	}
+	return make([]Token, 0, size)
+}
+
+// WithBatchSize sets how many tokens a lexer created by NewBatchLexerContext
+// sends at once. The default is 64. Other lexers, and values of n that are
+// not positive, are unaffected.
+func WithBatchSize(n int) Option {
+	return func(l *Lexer) {
+		if l.batchSize > 0 && n > 0 {
+			l.batchSize = n
+		}
+	}
+}
+
+// NewBatchLexerContext is like NewLexerContext, but the lexer sends its
+// tokens in batches, which costs one channel operation per batch rather than
+// one per token. The last batch may be short. Each batch belongs to the
+// receiver. WithBufferSize counts batches rather than tokens.
+func NewBatchLexerContext(ctx context.Context, input string, opts ...Option) (*Lexer, <-chan []Token) {
+	l := start(ctx, &Lexer{input: input, batchSize: defaultBatchSize}, opts)
+	return l, l.batches
+}
+
+// flush sends the current batch, if it is not empty, unless the lexer has
+// been cancelled.
+func (l *Lexer) flush() {
+	if len(l.batch) == 0 {
+		return
+	}
+
+	select {
+	case <-l.ctx.Done():
+		l.cancelled = true
+		return
+	default:
+	}
+
+	select {
+	case l.batches <- l.batch:
+		l.batch = newBatch(l.batchSize)
+	case <-l.ctx.Done():
+		l.cancelled = true
+	}
+}
+
+// Iterator returns the tokens of a batch lexer one at a time.
+type Iterator struct {
+	batches  <-chan []Token
+	batch    []Token // the unread part of received
+	received []Token
+}
+
+// NewIterator returns an Iterator over the batches from NewBatchLexerContext.
+func NewIterator(batches <-chan []Token) *Iterator {
+	return &Iterator{batches: batches}
+}
+
+// Next returns the next token, or false once the lexer has stopped and all
+// of its tokens have been returned.
+func (it *Iterator) Next() (Token, bool) {
+	for len(it.batch) == 0 {
+		if it.received != nil {
+			received := it.received
+			batchPool.Put(&received)
+			it.received = nil
+		}
+
+		batch, ok := <-it.batches
+		if !ok {
+			return Token{}, false
+		}
+		it.batch, it.received = batch, batch
+	}
+
+	t := it.batch[0]
+	it.batch = it.batch[1:]
+	return t, true
+}
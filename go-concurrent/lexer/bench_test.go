@@ -0,0 +1,69 @@
+package lexer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	monkey "monkey/lexer"
+)
+
+// benchmarkProgram only uses tokens that both this lexer and the go-devries
+// lexer understand.
+const benchmarkProgram = `let fibonacci = fn(n) {
+	if (n < 2) {
+		return n;
+	} else {
+		return fibonacci(n - 1) + fibonacci(n - 2);
+	}
+};
+
+let total = fibonacci(20) * 3 / 2;
+let ok = !(total == 12) != (total > 10);
+`
+
+// benchmarkCorpus is roughly a megabyte of Monkey source.
+var benchmarkCorpus = strings.Repeat(benchmarkProgram, 1<<20/len(benchmarkProgram))
+
+func BenchmarkMonkeyLexer(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkCorpus)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := monkey.NewLexer(benchmarkCorpus)
+		for tok := l.NextToken(); tok.Type != monkey.Eof; tok = l.NextToken() {
+		}
+	}
+}
+
+func BenchmarkChannelLexer(b *testing.B) {
+	for _, size := range []int{0, 64} {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			b.SetBytes(int64(len(benchmarkCorpus)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				_, tokens := NewLexerContext(context.Background(), benchmarkCorpus, WithBufferSize(size))
+				for range tokens {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBatchLexer(b *testing.B) {
+	for _, size := range []int{16, 64, 256, 1024} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			b.SetBytes(int64(len(benchmarkCorpus)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				_, batches := NewBatchLexerContext(context.Background(), benchmarkCorpus, WithBatchSize(size))
+				it := NewIterator(batches)
+				for _, ok := it.Next(); ok; _, ok = it.Next() {
+				}
+			}
+		})
+	}
+}
@@ -1,91 +1,184 @@
 package lexer
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"strings"
-	"unicode"
 	"unicode/utf8"
+
+	monkey "monkey/lexer"
 )
 
-type tokenType int
+// TokenType identifies the type of a Token.
+type TokenType int
 
 const (
-	tokenIllegal tokenType = iota
-	tokenEOF
-	tokenIdent
-	tokenInt
-	tokenAssign
-	tokenPlus
-	tokenMinus
-	tokenBang
-	tokenAsterisk
-	tokenSlash
-	tokenLessThan
-	tokenGreaterThan
-	tokenEqual
-	tokenNotEqual
-	tokenComma
-	tokenSemicolon
-	tokenLParen
-	tokenRParen
-	tokenLSquirly
-	tokenRSquirly
-	tokenFunction
-	tokenLet
-	tokenTrue
-	tokenFalse
-	tokenIf
-	tokenElse
-	tokenReturn
+	TokenIllegal TokenType = iota
+	TokenEOF
+	TokenIdent
+	TokenInt
+	TokenAssign
+	TokenPlus
+	TokenMinus
+	TokenBang
+	TokenAsterisk
+	TokenSlash
+	TokenLessThan
+	TokenGreaterThan
+	TokenEqual
+	TokenNotEqual
+	TokenComma
+	TokenSemicolon
+	TokenLParen
+	TokenRParen
+	TokenLSquirly
+	TokenRSquirly
+	TokenFunction
+	TokenLet
+	TokenTrue
+	TokenFalse
+	TokenIf
+	TokenElse
+	TokenReturn
 )
 
-type token struct {
-	typ tokenType
-	val string
+// Token is a token sent by a Lexer. Value is the text of the token, except
+// for TokenIllegal, whose Value describes the error that stopped the lexer.
+type Token struct {
+	Type  TokenType
+	Value string
 }
 
-func (t token) String() string {
-	switch t.typ {
-	case tokenEOF:
+func (t Token) String() string {
+	switch t.Type {
+	case TokenEOF:
 		return "EOF"
-	case tokenIllegal:
-		return t.val
+	case TokenIllegal:
+		return t.Value
 	}
-	if len(t.val) >= 10 {
-		return fmt.Sprintf("%.10q...", t.val)
+	if len(t.Value) >= 10 {
+		return fmt.Sprintf("%.10q...", t.Value)
 	}
-	return fmt.Sprintf("%q", t.val)
+	return fmt.Sprintf("%q", t.Value)
 }
 
-var keywords = map[string]tokenType{
-	"fn":     tokenFunction,
-	"let":    tokenLet,
-	"true":   tokenTrue,
-	"false":  tokenFalse,
-	"if":     tokenIf,
-	"else":   tokenElse,
-	"return": tokenReturn,
+var keywords = map[string]TokenType{
+	"fn":     TokenFunction,
+	"let":    TokenLet,
+	"true":   TokenTrue,
+	"false":  TokenFalse,
+	"if":     TokenIf,
+	"else":   TokenElse,
+	"return": TokenReturn,
 }
 
 const eof = -1
 
+// Lexer scans Monkey source with a state machine running in its own
+// goroutine.
 type Lexer struct {
 	input  string
 	start  int
 	pos    int
 	width  int
-	tokens chan token
+	tokens chan Token
+
+	// batches replaces tokens for a lexer created by NewBatchLexerContext,
+	// which collects tokens in batch until it holds batchSize of them.
+	batches   chan []Token
+	batch     []Token
+	batchSize int
+
+	// reader, if not nil, supplies the input as the lexer needs it.
+	reader  io.Reader
+	buf     []byte
+	readErr error
+
+	// lineEnds makes a newline after a token that can end a statement a
+	// TokenSemicolon; last is the type of the token sent before it.
+	lineEnds bool
+	last     TokenType
+
+	bufferSize int
+	ctx        context.Context
+	cancel     context.CancelFunc
+	cancelled  bool
+	stopped    chan struct{}
 }
 
-func NewLexer(input string) (*Lexer, chan token) {
-	l := &Lexer{
-		input:  input,
-		tokens: make(chan token),
+// Option configures a Lexer created by NewLexerContext and the like.
+type Option func(*Lexer)
+
+// WithBufferSize sets how many tokens the lexer may run ahead of its
+// consumer. The default is 0, an unbuffered channel. n must not be negative.
+func WithBufferSize(n int) Option {
+	return func(l *Lexer) {
+		l.bufferSize = n
 	}
-	go l.run() // Concurrently run state machine.
+}
+
+// WithLineEnds makes the lexer send a newline that follows an identifier, a
+// literal, ")" or "}" as a TokenSemicolon, so that a line ends a statement
+// the way Go's automatic semicolons do. A statement that goes on over lines
+// must then break after an operator or an opening bracket. The newline is
+// sent as soon as it is read, without waiting for the next line.
+func WithLineEnds() Option {
+	return func(l *Lexer) {
+		l.lineEnds = true
+	}
+}
+
+// NewLexer starts lexing input in a new goroutine and returns the lexer with
+// the channel it sends tokens on. The channel is closed after a TokenEOF or
+// TokenIllegal token.
+func NewLexer(input string) (*Lexer, <-chan Token) {
+	return NewLexerContext(context.Background(), input)
+}
+
+// NewLexerContext is like NewLexer, but the lexer stops early and closes its
+// channel when ctx is done or Close is called, so a consumer may abandon the
+// token stream without leaking the lexer goroutine.
+func NewLexerContext(ctx context.Context, input string, opts ...Option) (*Lexer, <-chan Token) {
+	l := start(ctx, &Lexer{input: input}, opts)
 	return l, l.tokens
 }
 
+// NewReaderLexerContext is like NewLexerContext, but reads its input from r
+// as it goes, sending each token as soon as it is complete rather than once
+// all of the input has been read. A lexer blocked reading r can only stop
+// once the read returns. A read error other than io.EOF stops the lexer
+// with a TokenIllegal token.
+func NewReaderLexerContext(ctx context.Context, r io.Reader, opts ...Option) (*Lexer, <-chan Token) {
+	l := start(ctx, &Lexer{reader: r, buf: make([]byte, 4096)}, opts)
+	return l, l.tokens
+}
+
+func start(ctx context.Context, l *Lexer, opts []Option) *Lexer {
+	l.stopped = make(chan struct{})
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	if l.batchSize > 0 {
+		l.batches = make(chan []Token, l.bufferSize)
+		l.batch = newBatch(l.batchSize)
+	} else {
+		l.tokens = make(chan Token, l.bufferSize)
+	}
+
+	go l.run() // Concurrently run state machine.
+	return l
+}
+
+// Close stops the lexer and waits for its goroutine to exit. Tokens already
+// buffered in the channel can still be received. Close may be called more
+// than once.
+func (l *Lexer) Close() {
+	l.cancel()
+	<-l.stopped
+}
+
 // stateFn represents the state of the scanner
 // as a function that returns the next state.
 type stateFn func(*Lexer) stateFn
@@ -93,93 +186,108 @@ type stateFn func(*Lexer) stateFn
 // run lexes the input by executing state functions
 // until the state is nil.
 func (l *Lexer) run() {
-	for state := lex; state != nil; {
+	defer close(l.stopped)
+	defer l.cancel()
+
+	for state := lex; state != nil && !l.cancelled; {
 		state = state(l)
 	}
-	close(l.tokens)
+
+	if l.batches != nil {
+		l.flush()
+		close(l.batches)
+	} else {
+		close(l.tokens)
+	}
 }
 
 func lex(l *Lexer) stateFn {
-	for {
+	for !l.cancelled {
 		switch r := l.next(); {
+		case r == '\n' && l.lineEnds && endsStatement(l.last):
+			l.emit(TokenSemicolon)
 		case isSpace(r):
 			l.ignore()
 		case r == '=':
 			if l.peek() == '=' {
 				l.next()
-				l.emit(tokenEqual)
+				l.emit(TokenEqual)
 			} else {
-				l.emit(tokenAssign)
+				l.emit(TokenAssign)
 			}
 		case r == '+':
-			l.emit(tokenPlus)
+			l.emit(TokenPlus)
 		case r == '-':
-			l.emit(tokenMinus)
+			l.emit(TokenMinus)
 		case r == '!':
 			if l.peek() == '=' {
 				l.next()
-				l.emit(tokenNotEqual)
+				l.emit(TokenNotEqual)
 			} else {
-				l.emit(tokenBang)
+				l.emit(TokenBang)
 			}
 		case r == '/':
-			l.emit(tokenSlash)
+			l.emit(TokenSlash)
 		case r == '*':
-			l.emit(tokenAsterisk)
+			l.emit(TokenAsterisk)
 		case r == '<':
-			l.emit(tokenLessThan)
+			l.emit(TokenLessThan)
 		case r == '>':
-			l.emit(tokenGreaterThan)
+			l.emit(TokenGreaterThan)
 		case r == ';':
-			l.emit(tokenSemicolon)
+			l.emit(TokenSemicolon)
 		case r == ',':
-			l.emit(tokenComma)
+			l.emit(TokenComma)
 		case r == '(':
-			l.emit(tokenLParen)
+			l.emit(TokenLParen)
 		case r == ')':
-			l.emit(tokenRParen)
+			l.emit(TokenRParen)
 		case r == '{':
-			l.emit(tokenLSquirly)
+			l.emit(TokenLSquirly)
 		case r == '}':
-			l.emit(tokenRSquirly)
+			l.emit(TokenRSquirly)
 		case '0' <= r && r <= '9':
 			l.backup()
 			return lexNumber
-		case isAlphaNumeric(r):
+		case isIdentStart(r):
 			l.backup()
 			return lexIdent
 		case r == eof:
-			l.emit(tokenEOF)
+			if l.readErr != nil {
+				return l.errorf("read error: %v", l.readErr)
+			}
+			l.emit(TokenEOF)
 			return nil
 		default:
 			return l.errorf("unrecognized character in action: %#U", r)
 		}
 	}
+	return nil
 }
 
 func lexNumber(l *Lexer) stateFn {
 	digits := "0123456789"
 	l.acceptRun(digits)
 
-	if isAlphaNumeric(l.peek()) {
+	if isIdentContinue(l.peek()) {
 		l.next()
 		return l.errorf("bad number syntax: %q", l.input[l.start:l.pos])
 	}
 
-	l.emit(tokenInt)
+	l.emit(TokenInt)
 
 	return lex
 }
 
 func lexIdent(l *Lexer) stateFn {
-	for isAlphaNumeric(l.next()) {
+	for isIdentContinue(l.next()) {
 	}
 	l.backup()
 
 	if t, ok := keywords[l.input[l.start:l.pos]]; ok {
 		l.emit(t)
 	} else {
-		l.emit(tokenIdent)
+		l.emit(TokenIdent)
 	}
 
 	return lex
@@ -191,6 +299,8 @@ func lexComment(l *Lexer) stateFn {
 }
 
 func (l *Lexer) next() (r rune) {
+	for !utf8.FullRuneInString(l.input[l.pos:]) && l.refill() {
+	}
 	if l.pos >= len(l.input) {
 		l.width = 0
 		return eof
@@ -200,6 +310,28 @@ func (l *Lexer) next() (r rune) {
 	return r
 }
 
+// refill reads more input from the reader, first dropping the input before
+// the current token. It reports whether any input was added.
+func (l *Lexer) refill() bool {
+	if l.reader == nil {
+		return false
+	}
+
+	l.input = l.input[l.start:]
+	l.pos -= l.start
+	l.start = 0
+
+	n, err := l.reader.Read(l.buf)
+	l.input += string(l.buf[:n])
+	if err != nil {
+		if err != io.EOF {
+			l.readErr = err
+		}
+		l.reader = nil
+	}
+	return n > 0 || l.reader != nil
+}
+
 func (l *Lexer) peek() rune {
 	r := l.next()
 	l.backup()
@@ -210,9 +342,45 @@ func (l *Lexer) backup() {
 	l.pos -= l.width
 }
 
-func (l *Lexer) emit(t tokenType) {
-	l.tokens <- token{t, l.input[l.start:l.pos]}
+func (l *Lexer) emit(t TokenType) {
+	l.send(Token{t, l.input[l.start:l.pos]})
 	l.start = l.pos
+	l.last = t
+}
+
+// endsStatement reports whether a token of type t can be the last one of a
+// statement.
+func endsStatement(t TokenType) bool {
+	switch t {
+	case TokenIdent, TokenInt, TokenTrue, TokenFalse, TokenRParen, TokenRSquirly:
+		return true
+	}
+	return false
+}
+
+// send delivers t unless the lexer has been cancelled, in which case it
+// records that so the state machine stops.
+func (l *Lexer) send(t Token) {
+	if l.batches != nil {
+		l.batch = append(l.batch, t)
+		if len(l.batch) == l.batchSize {
+			l.flush()
+		}
+		return
+	}
+
+	select {
+	case <-l.ctx.Done():
+		l.cancelled = true
+		return
+	default:
+	}
+
+	select {
+	case l.tokens <- t:
+	case <-l.ctx.Done():
+		l.cancelled = true
+	}
 }
 
 func (l *Lexer) ignore() {
@@ -226,17 +394,24 @@ func (l *Lexer) acceptRun(valid string) {
 }
 
 func (l *Lexer) errorf(format string, args ...interface{}) stateFn {
-	l.tokens <- token{
-		tokenIllegal,
+	l.send(Token{
+		TokenIllegal,
 		fmt.Sprintf(format, args...),
-	}
+	})
 	return nil
 }
 
+// Identifiers and whitespace follow the same Unicode rules as in the monkey
+// lexer.
+
 func isSpace(r rune) bool {
-	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	return monkey.IsWhitespace(r)
+}
+
+func isIdentStart(r rune) bool {
+	return monkey.IsIdentStart(r)
 }
 
-func isAlphaNumeric(r rune) bool {
-	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+func isIdentContinue(r rune) bool {
+	return monkey.IsIdentContinue(r)
 }
@@ -1,26 +1,36 @@
 package lexer
 
 import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	monkey "monkey/lexer"
+	"monkey/parser"
 )
 
 func TestLexer(t *testing.T) {
 	cases := []struct {
 		input  string
-		expect []token
+		expect []Token
 	}{
 		{
 			input: `=+(){},;`,
-			expect: []token{
-				{tokenAssign, "="},
-				{tokenPlus, "+"},
-				{tokenLParen, "("},
-				{tokenRParen, ")"},
-				{tokenLSquirly, "{"},
-				{tokenRSquirly, "}"},
-				{tokenComma, ","},
-				{tokenSemicolon, ";"},
-				{tokenEOF, ""},
+			expect: []Token{
+				{TokenAssign, "="},
+				{TokenPlus, "+"},
+				{TokenLParen, "("},
+				{TokenRParen, ")"},
+				{TokenLSquirly, "{"},
+				{TokenRSquirly, "}"},
+				{TokenComma, ","},
+				{TokenSemicolon, ";"},
+				{TokenEOF, ""},
 			},
 		},
 		{
@@ -30,44 +40,44 @@ func TestLexer(t *testing.T) {
 				x + y;
 			};
 			let result = add(five, ten);`,
-			expect: []token{
-				{tokenLet, "let"},
-				{tokenIdent, "five"},
-				{tokenAssign, "="},
-				{tokenInt, "5"},
-				{tokenSemicolon, ";"},
-				{tokenLet, "let"},
-				{tokenIdent, "ten"},
-				{tokenAssign, "="},
-				{tokenInt, "10"},
-				{tokenSemicolon, ";"},
-				{tokenLet, "let"},
-				{tokenIdent, "add"},
-				{tokenAssign, "="},
-				{tokenFunction, "fn"},
-				{tokenLParen, "("},
-				{tokenIdent, "x"},
-				{tokenComma, ","},
-				{tokenIdent, "y"},
-				{tokenRParen, ")"},
-				{tokenLSquirly, "{"},
-				{tokenIdent, "x"},
-				{tokenPlus, "+"},
-				{tokenIdent, "y"},
-				{tokenSemicolon, ";"},
-				{tokenRSquirly, "}"},
-				{tokenSemicolon, ";"},
-				{tokenLet, "let"},
-				{tokenIdent, "result"},
-				{tokenAssign, "="},
-				{tokenIdent, "add"},
-				{tokenLParen, "("},
-				{tokenIdent, "five"},
-				{tokenComma, ","},
-				{tokenIdent, "ten"},
-				{tokenRParen, ")"},
-				{tokenSemicolon, ";"},
-				{tokenEOF, ""},
+			expect: []Token{
+				{TokenLet, "let"},
+				{TokenIdent, "five"},
+				{TokenAssign, "="},
+				{TokenInt, "5"},
+				{TokenSemicolon, ";"},
+				{TokenLet, "let"},
+				{TokenIdent, "ten"},
+				{TokenAssign, "="},
+				{TokenInt, "10"},
+				{TokenSemicolon, ";"},
+				{TokenLet, "let"},
+				{TokenIdent, "add"},
+				{TokenAssign, "="},
+				{TokenFunction, "fn"},
+				{TokenLParen, "("},
+				{TokenIdent, "x"},
+				{TokenComma, ","},
+				{TokenIdent, "y"},
+				{TokenRParen, ")"},
+				{TokenLSquirly, "{"},
+				{TokenIdent, "x"},
+				{TokenPlus, "+"},
+				{TokenIdent, "y"},
+				{TokenSemicolon, ";"},
+				{TokenRSquirly, "}"},
+				{TokenSemicolon, ";"},
+				{TokenLet, "let"},
+				{TokenIdent, "result"},
+				{TokenAssign, "="},
+				{TokenIdent, "add"},
+				{TokenLParen, "("},
+				{TokenIdent, "five"},
+				{TokenComma, ","},
+				{TokenIdent, "ten"},
+				{TokenRParen, ")"},
+				{TokenSemicolon, ";"},
+				{TokenEOF, ""},
 			},
 		},
 		{
@@ -82,45 +92,45 @@ func TestLexer(t *testing.T) {
 			
 			10 == 10;
 			10 != 9;`,
-			expect: []token{
-				{tokenBang, "!"},
-				{tokenMinus, "-"},
-				{tokenSlash, "/"},
-				{tokenAsterisk, "*"},
-				{tokenInt, "5"},
-				{tokenSemicolon, ";"},
-				{tokenInt, "5"},
-				{tokenLessThan, "<"},
-				{tokenInt, "10"},
-				{tokenGreaterThan, ">"},
-				{tokenInt, "5"},
-				{tokenSemicolon, ";"},
-				{tokenIf, "if"},
-				{tokenLParen, "("},
-				{tokenInt, "5"},
-				{tokenLessThan, "<"},
-				{tokenInt, "10"},
-				{tokenRParen, ")"},
-				{tokenLSquirly, "{"},
-				{tokenReturn, "return"},
-				{tokenTrue, "true"},
-				{tokenSemicolon, ";"},
-				{tokenRSquirly, "}"},
-				{tokenElse, "else"},
-				{tokenLSquirly, "{"},
-				{tokenReturn, "return"},
-				{tokenFalse, "false"},
-				{tokenSemicolon, ";"},
-				{tokenRSquirly, "}"},
-				{tokenInt, "10"},
-				{tokenEqual, "=="},
-				{tokenInt, "10"},
-				{tokenSemicolon, ";"},
-				{tokenInt, "10"},
-				{tokenNotEqual, "!="},
-				{tokenInt, "9"},
-				{tokenSemicolon, ";"},
-				{tokenEOF, ""},
+			expect: []Token{
+				{TokenBang, "!"},
+				{TokenMinus, "-"},
+				{TokenSlash, "/"},
+				{TokenAsterisk, "*"},
+				{TokenInt, "5"},
+				{TokenSemicolon, ";"},
+				{TokenInt, "5"},
+				{TokenLessThan, "<"},
+				{TokenInt, "10"},
+				{TokenGreaterThan, ">"},
+				{TokenInt, "5"},
+				{TokenSemicolon, ";"},
+				{TokenIf, "if"},
+				{TokenLParen, "("},
+				{TokenInt, "5"},
+				{TokenLessThan, "<"},
+				{TokenInt, "10"},
+				{TokenRParen, ")"},
+				{TokenLSquirly, "{"},
+				{TokenReturn, "return"},
+				{TokenTrue, "true"},
+				{TokenSemicolon, ";"},
+				{TokenRSquirly, "}"},
+				{TokenElse, "else"},
+				{TokenLSquirly, "{"},
+				{TokenReturn, "return"},
+				{TokenFalse, "false"},
+				{TokenSemicolon, ";"},
+				{TokenRSquirly, "}"},
+				{TokenInt, "10"},
+				{TokenEqual, "=="},
+				{TokenInt, "10"},
+				{TokenSemicolon, ";"},
+				{TokenInt, "10"},
+				{TokenNotEqual, "!="},
+				{TokenInt, "9"},
+				{TokenSemicolon, ";"},
+				{TokenEOF, ""},
 			},
 		},
 	}
@@ -140,3 +150,327 @@ func TestLexer(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenSource(t *testing.T) {
+	input := `let add = fn(x, y) {
+		return x + y;
+	};
+	if (add(1, 2) != 3) { !true } else { 10 / 2 * 5 < 9 };`
+
+	source := NewTokenSource(input)
+	reference := monkey.NewLexer(input)
+	for {
+		expectedToken := reference.NextToken()
+		if token := source.NextToken(); token != expectedToken {
+			t.Fatalf("expected %v; got %v", expectedToken, token)
+		}
+		if expectedToken.Type == monkey.Eof {
+			break
+		}
+	}
+
+	p := parser.New(NewTokenSource(input))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("unexpected parser errors: %v", p.Errors)
+	}
+
+	expected := parser.New(monkey.NewLexer(input)).ParseProgram()
+	if program.String() != expected.String() {
+		t.Errorf("expected %q; got %q", expected.String(), program.String())
+	}
+}
+
+func TestTokenSourceAfterError(t *testing.T) {
+	source := NewTokenSource("let x = 5 @")
+
+	var last monkey.Token
+	for i := 0; i < 10; i++ {
+		last = source.NextToken()
+	}
+	if last.Type != monkey.Eof {
+		t.Errorf("expected Eof after the lexer stopped; got %v", last)
+	}
+}
+
+// verifyNoLeakedLexers fails the test if a lexer goroutine is still running
+// shortly after the test, in the spirit of go.uber.org/goleak.
+func verifyNoLeakedLexers(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		if !strings.Contains(stacks, "lexer.(*Lexer).run") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lexer goroutines leaked:\n%s", stacks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLexerClose(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	l, tokens := NewLexerContext(context.Background(), strings.Repeat("let x = 5;\n", 1000))
+	expectedToken := Token{TokenLet, "let"}
+	if token := <-tokens; token != expectedToken {
+		t.Fatalf("expected %v; got %v", expectedToken, token)
+	}
+	l.Close()
+	l.Close()
+
+	for range tokens {
+	}
+}
+
+func TestLexerContextCancel(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, tokens := NewLexerContext(ctx, strings.Repeat("let x = 5;\n", 1000))
+	<-tokens
+	cancel()
+
+	n := 0
+	for range tokens {
+		n++
+	}
+	if n > 1 {
+		t.Errorf("expected at most 1 token after cancel; got %d", n)
+	}
+}
+
+func TestLexerAbandonedAfterError(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	source := NewTokenSource("let x = @; let y = 10;")
+	defer source.Close()
+
+	for token := source.NextToken(); token.Type != monkey.Illegal; token = source.NextToken() {
+	}
+}
+
+func TestLexerBufferSize(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	l, tokens := NewLexerContext(context.Background(), "let x = 5; let y = 10;", WithBufferSize(4))
+	defer l.Close()
+
+	if cap(tokens) != 4 {
+		t.Fatalf("expected a buffer of 4 tokens; got %d", cap(tokens))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(tokens) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the lexer to fill its buffer; got %d tokens", len(tokens))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	expect := []Token{{TokenLet, "let"}, {TokenIdent, "x"}, {TokenAssign, "="}, {TokenInt, "5"}}
+	for _, expectedToken := range expect {
+		if token := <-tokens; token != expectedToken {
+			t.Errorf("expected %v; got %v", expectedToken, token)
+		}
+	}
+}
+
+func TestLineEnds(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	input := "let x = 1 +\n2\nf(x)\nfn(a) {\n\ta\n}\n\ntrue;\n"
+	_, tokens := NewLexerContext(context.Background(), input, WithLineEnds())
+
+	expected := []Token{
+		{TokenLet, "let"}, {TokenIdent, "x"}, {TokenAssign, "="}, {TokenInt, "1"}, {TokenPlus, "+"},
+		{TokenInt, "2"}, {TokenSemicolon, "\n"},
+		{TokenIdent, "f"}, {TokenLParen, "("}, {TokenIdent, "x"}, {TokenRParen, ")"}, {TokenSemicolon, "\n"},
+		{TokenFunction, "fn"}, {TokenLParen, "("}, {TokenIdent, "a"}, {TokenRParen, ")"}, {TokenLSquirly, "{"},
+		{TokenIdent, "a"}, {TokenSemicolon, "\n"},
+		{TokenRSquirly, "}"}, {TokenSemicolon, "\n"},
+		{TokenTrue, "true"}, {TokenSemicolon, ";"},
+		{TokenEOF, ""},
+	}
+	for _, expectedToken := range expected {
+		if token := <-tokens; token != expectedToken {
+			t.Errorf("expected %v; got %v", expectedToken, token)
+		}
+	}
+}
+
+func TestReaderLexer(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	input := "let π = fn(x, y) {\n\tx + y; };\nπ(1, 22) != 333;"
+	_, expected := NewLexer(input)
+	_, tokens := NewReaderLexerContext(context.Background(), iotest.OneByteReader(strings.NewReader(input)))
+
+	for expectedToken := range expected {
+		if token := <-tokens; token != expectedToken {
+			t.Errorf("expected %v; got %v", expectedToken, token)
+		}
+	}
+	if token, ok := <-tokens; ok {
+		t.Errorf("expected the channel to be closed; got %v", token)
+	}
+}
+
+func TestReaderLexerError(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	r := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(errors.New("broken")))
+	_, tokens := NewReaderLexerContext(context.Background(), r)
+
+	expect := []Token{
+		{TokenLet, "let"},
+		{TokenIdent, "x"},
+		{TokenIllegal, "read error: broken"},
+	}
+	for _, expectedToken := range expect {
+		if token := <-tokens; token != expectedToken {
+			t.Errorf("expected %v; got %v", expectedToken, token)
+		}
+	}
+}
+
+func TestBatchLexer(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	input := `let add = fn(x, y) { x + y; };
+	if (add(5, 10) != 15) { return false; } else { return !true; }`
+
+	var expect []Token
+	_, tokens := NewLexer(input)
+	for token := range tokens {
+		expect = append(expect, token)
+	}
+
+	for _, size := range []int{1, 3, 64} {
+		_, batches := NewBatchLexerContext(context.Background(), input, WithBatchSize(size))
+
+		var got []Token
+		for batch := range batches {
+			if len(batch) == 0 || len(batch) > size {
+				t.Errorf("batch size %d: got a batch of %d tokens", size, len(batch))
+			}
+			if len(batch) < size && len(got)+len(batch) != len(expect) {
+				t.Errorf("batch size %d: got a short batch of %d tokens before the end", size, len(batch))
+			}
+			got = append(got, batch...)
+		}
+
+		if len(got) != len(expect) {
+			t.Fatalf("batch size %d: expected %d tokens; got %d", size, len(expect), len(got))
+		}
+		for i := range expect {
+			if got[i] != expect[i] {
+				t.Errorf("batch size %d: expected %v; got %v", size, expect[i], got[i])
+			}
+		}
+	}
+}
+
+func TestIterator(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	_, batches := NewBatchLexerContext(context.Background(), "let x = 5;", WithBatchSize(2))
+	it := NewIterator(batches)
+
+	expect := []Token{
+		{TokenLet, "let"},
+		{TokenIdent, "x"},
+		{TokenAssign, "="},
+		{TokenInt, "5"},
+		{TokenSemicolon, ";"},
+		{TokenEOF, ""},
+	}
+	for _, expectedToken := range expect {
+		if token, ok := it.Next(); !ok || token != expectedToken {
+			t.Errorf("expected %v; got %v, %v", expectedToken, token, ok)
+		}
+	}
+	if token, ok := it.Next(); ok {
+		t.Errorf("expected the iterator to end; got %v", token)
+	}
+}
+
+func TestBatchLexerClose(t *testing.T) {
+	defer verifyNoLeakedLexers(t)
+
+	l, batches := NewBatchLexerContext(context.Background(), strings.Repeat("let x = 5;\n", 1000), WithBatchSize(8))
+	it := NewIterator(batches)
+	it.Next()
+	l.Close()
+
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+	}
+}
+
+func TestIteratorSource(t *testing.T) {
+	input := "let add = fn(x, y) { x + y; }; add(1, 2 * 3);"
+
+	_, batches := NewBatchLexerContext(context.Background(), input, WithBatchSize(4))
+	p := parser.New(NewIteratorSource(NewIterator(batches)))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("unexpected parser errors: %v", p.Errors)
+	}
+
+	expected := parser.New(monkey.NewLexer(input)).ParseProgram()
+	if program.String() != expected.String() {
+		t.Errorf("expected %q; got %q", expected.String(), program.String())
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	cases := []struct {
+		input  string
+		expect []Token
+	}{
+		{
+			input: "let café = 名前_2 + x٣;",
+			expect: []Token{
+				{TokenLet, "let"},
+				{TokenIdent, "café"},
+				{TokenAssign, "="},
+				{TokenIdent, "名前_2"},
+				{TokenPlus, "+"},
+				{TokenIdent, "x٣"},
+				{TokenSemicolon, ";"},
+				{TokenEOF, ""},
+			},
+		},
+		{
+			// A combining mark continues an identifier but cannot start one.
+			input: "e\u0301 \u0301",
+			expect: []Token{
+				{TokenIdent, "e\u0301"},
+				{TokenIllegal, "unrecognized character in action: U+0301 '\u0301'"},
+			},
+		},
+		{
+			// The no-break space is not Pattern_White_Space, unlike U+2028.
+			input: "a\u2028b\u00a0c",
+			expect: []Token{
+				{TokenIdent, "a"},
+				{TokenIdent, "b"},
+				{TokenIllegal, "unrecognized character in action: U+00A0"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		_, tokens := NewLexer(c.input)
+
+		for _, expectedToken := range c.expect {
+			if token := <-tokens; token != expectedToken {
+				t.Errorf("%q: expected %v; got %v", c.input, expectedToken, token)
+			}
+		}
+	}
+}
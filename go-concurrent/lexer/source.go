@@ -0,0 +1,106 @@
+package lexer
+
+import (
+	"context"
+	"errors"
+
+	monkey "monkey/lexer"
+)
+
+var monkeyTokenTypes = map[TokenType]monkey.TokenType{
+	TokenIllegal:     monkey.Illegal,
+	TokenEOF:         monkey.Eof,
+	TokenIdent:       monkey.Ident,
+	TokenInt:         monkey.Int,
+	TokenAssign:      monkey.Assign,
+	TokenPlus:        monkey.Plus,
+	TokenMinus:       monkey.Minus,
+	TokenBang:        monkey.Bang,
+	TokenAsterisk:    monkey.Asterisk,
+	TokenSlash:       monkey.ForwardSlash,
+	TokenLessThan:    monkey.LessThan,
+	TokenGreaterThan: monkey.GreaterThan,
+	TokenEqual:       monkey.Equal,
+	TokenNotEqual:    monkey.NotEqual,
+	TokenComma:       monkey.Comma,
+	TokenSemicolon:   monkey.Semicolon,
+	TokenLParen:      monkey.LParen,
+	TokenRParen:      monkey.RParen,
+	TokenLSquirly:    monkey.LSquirly,
+	TokenRSquirly:    monkey.RSquirly,
+	TokenFunction:    monkey.Function,
+	TokenLet:         monkey.Let,
+	TokenTrue:        monkey.True,
+	TokenFalse:       monkey.False,
+	TokenIf:          monkey.If,
+	TokenElse:        monkey.Else,
+	TokenReturn:      monkey.Return,
+}
+
+// TokenSource adapts the tokens of a Lexer to the monkey parser's
+// TokenSource interface, translating each token into a monkey/lexer token.
+type TokenSource struct {
+	lexer *Lexer
+	next  func() (Token, bool)
+	err   error
+}
+
+// NewChannelSource returns a TokenSource reading tokens from a channel, such
+// as one returned by NewLexer.
+func NewChannelSource(tokens <-chan Token) *TokenSource {
+	return &TokenSource{next: receiver(tokens)}
+}
+
+// NewIteratorSource returns a TokenSource reading tokens from an Iterator
+// over the batches of a batch lexer.
+func NewIteratorSource(it *Iterator) *TokenSource {
+	return &TokenSource{next: it.Next}
+}
+
+func receiver(tokens <-chan Token) func() (Token, bool) {
+	return func() (Token, bool) {
+		t, ok := <-tokens
+		return t, ok
+	}
+}
+
+// NewTokenSource starts a Lexer on input and returns a TokenSource reading
+// its tokens.
+func NewTokenSource(input string) *TokenSource {
+	return NewTokenSourceContext(context.Background(), input)
+}
+
+// NewTokenSourceContext is like NewTokenSource, but the lexer is created with
+// NewLexerContext and stops when ctx is done.
+func NewTokenSourceContext(ctx context.Context, input string, opts ...Option) *TokenSource {
+	l, tokens := NewLexerContext(ctx, input, opts...)
+	return &TokenSource{lexer: l, next: receiver(tokens)}
+}
+
+// Close stops the underlying lexer. Use it when parsing ends before the
+// token stream does. Close does nothing for a TokenSource created by
+// NewChannelSource or NewIteratorSource, whose lexer belongs to the caller.
+func (s *TokenSource) Close() {
+	if s.lexer != nil {
+		s.lexer.Close()
+	}
+}
+
+// Err returns the error reported by the first TokenIllegal token read, if
+// any.
+func (s *TokenSource) Err() error {
+	return s.err
+}
+
+// NextToken returns the next token. After the lexer stops, whether at the end
+// of the input or after an error, it returns Eof.
+func (s *TokenSource) NextToken() monkey.Token {
+	t, ok := s.next()
+	if !ok {
+		return monkey.Token{Type: monkey.Eof}
+	}
+	if t.Type == TokenIllegal && s.err == nil {
+		s.err = errors.New(t.Value)
+	}
+	return monkey.Token{Type: monkeyTokenTypes[t.Type], Literal: t.Value}
+}
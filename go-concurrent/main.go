@@ -1,32 +1,116 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"monkey/object"
 	"monkeylang/lexer"
+	"monkeylang/pipeline"
 )
 
 func main() {
-	input := `let five = 5;
-	let ten = 10;
-	let add = fn(x, y) {
-		x + y;
-	};
-	let result = add(five, ten);
-	!-/*5;
-	5 < 10 > 5;
-
-	if (5 < 10) {
-		return true;
+	tokens := flag.Bool("tokens", false, "print the tokens instead of running the program")
+	ast := flag.Bool("ast", false, "print the parsed statements instead of running the program")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-tokens | -ast] [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	input := os.Stdin
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		input = f
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch {
+	case *tokens:
+		err = printTokens(ctx, input)
+	case *ast:
+		err = printStatements(ctx, input)
+	default:
+		err = run(ctx, input, isTerminal(input))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run executes the program read from r statement by statement, as soon as
+// each one has been read. An interactive session ends a statement at the end
+// of a line, prints the value of each statement and carries on after errors;
+// otherwise the first error stops the program.
+func run(ctx context.Context, r io.Reader, interactive bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var opts []pipeline.Option
+	if interactive {
+		opts = append(opts, pipeline.LineEnds())
 	} else {
-		return false;
+		opts = append(opts, pipeline.StopOnError())
 	}
 
-	10 == 10;
-	10 != 9;`
+	for result := range pipeline.Run(ctx, r, object.NewEnvironment(), opts...) {
+		switch {
+		case result.Err != nil && interactive:
+			fmt.Fprintln(os.Stderr, result.Err)
+		case result.Err != nil:
+			return result.Err
+		case interactive && result.Value != nil:
+			fmt.Println(result.Value.Inspect())
+		}
+	}
+	return ctx.Err()
+}
 
-	_, tokens := lexer.NewLexer(input)
+// printStatements prints each statement read from r as soon as it is parsed.
+func printStatements(ctx context.Context, r io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
+	_, tokens := lexer.NewReaderLexerContext(ctx, r)
+	for stmt := range pipeline.Parse(ctx, lexer.NewChannelSource(tokens)) {
+		for _, msg := range stmt.Errors {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		if len(stmt.Errors) == 0 {
+			fmt.Println(stmt.Node)
+		}
+	}
+	return ctx.Err()
+}
+
+// printTokens prints each token read from r as soon as it is complete.
+func printTokens(ctx context.Context, r io.Reader) error {
+	_, tokens := lexer.NewReaderLexerContext(ctx, r)
 	for t := range tokens {
 		fmt.Println(t)
 	}
+	return ctx.Err()
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
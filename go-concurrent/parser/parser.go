@@ -0,0 +1,36 @@
+// Package parser parses Monkey programs from the token channel of a
+// concurrent lexer. The parsing itself is done by the monkey parser, which
+// reads the channel through a lexer.TokenSource.
+package parser
+
+import (
+	"context"
+
+	"monkey/ast"
+	monkey "monkey/parser"
+	"monkeylang/lexer"
+)
+
+// New returns a parser reading tokens from the channel of a Lexer.
+func New(tokens <-chan lexer.Token) *monkey.Parser {
+	return monkey.New(lexer.NewChannelSource(tokens))
+}
+
+// Parse lexes input concurrently and parses it, stopping the lexer if ctx is
+// done first. The lexer's error, if any, comes before the parser's errors.
+func Parse(ctx context.Context, input string) (*ast.Program, []string) {
+	source := lexer.NewTokenSourceContext(ctx, input)
+	defer source.Close()
+
+	p := monkey.New(source)
+	program := p.ParseProgram()
+
+	var errors []string
+	if err := ctx.Err(); err != nil {
+		errors = append(errors, err.Error())
+	}
+	if err := source.Err(); err != nil {
+		errors = append(errors, err.Error())
+	}
+	return program, append(errors, p.Errors...)
+}
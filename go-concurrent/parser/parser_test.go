@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	monkeylexer "monkey/lexer"
+	monkey "monkey/parser"
+	"monkeylang/lexer"
+)
+
+func TestParse(t *testing.T) {
+	input := `let add = fn(x, y) { x + y; };
+	let result = add(5, 10 * 2);
+	if (result < 30) { return true; } else { return false; }`
+
+	program, errors := Parse(context.Background(), input)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %v", errors)
+	}
+
+	expected := monkey.New(monkeylexer.NewLexer(input)).ParseProgram()
+	if program.String() != expected.String() {
+		t.Errorf("expected %q; got %q", expected.String(), program.String())
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, tokens := lexer.NewLexer("let x = !y == 5;")
+	p := New(tokens)
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", p.Errors)
+	}
+
+	if expected := "let x = ((!y) == 5);"; program.String() != expected {
+		t.Errorf("expected %q; got %q", expected, program.String())
+	}
+}
+
+func TestParseLexerError(t *testing.T) {
+	_, errors := Parse(context.Background(), "let x = 5 @ 3;")
+	if len(errors) == 0 {
+		t.Fatal("expected errors")
+	}
+	if !strings.Contains(errors[0], "unrecognized character") {
+		t.Errorf("expected the lexer error first; got %v", errors)
+	}
+}
+
+func TestParseCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, errors := Parse(ctx, strings.Repeat("let x = 5;\n", 1000))
+	if len(errors) == 0 || errors[0] != context.Canceled.Error() {
+		t.Errorf("expected %q first; got %v", context.Canceled, errors)
+	}
+}
@@ -0,0 +1,211 @@
+// Package pipeline runs Monkey programs as three concurrent stages: the
+// lexer goroutine, a parse stage that sends each top-level statement as soon
+// as it is complete, and an execute stage that evaluates the statements in
+// order. Execution therefore starts before all of the input has been read.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/parser"
+	"monkeylang/lexer"
+)
+
+// Statement is a top-level statement sent by the parse stage. If it has
+// syntax errors, Errors lists them and Node may be nil or incomplete.
+type Statement struct {
+	Node   ast.Statement
+	Errors []string
+}
+
+// Result is the outcome of executing one statement: the value it evaluated
+// to, or the error that prevented it.
+type Result struct {
+	Statement ast.Statement
+	Value     object.Object
+	Err       error
+}
+
+// ParseError is the error of a Result for a statement with syntax errors.
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// Option configures the stages started by Run or Execute.
+type Option func(*options)
+
+type options struct {
+	stopOnError bool
+	lineEnds    bool
+}
+
+// StopOnError makes the first statement with an error end the program, as
+// it would in evaluator.Eval. By default execution carries on, as suits a
+// REPL.
+func StopOnError() Option {
+	return func(o *options) {
+		o.stopOnError = true
+	}
+}
+
+// LineEnds makes Run end a statement at a newline wherever it could end, as
+// if a semicolon were there; see lexer.WithLineEnds. An interactive session
+// needs it: without a semicolon, the parser cannot tell that a statement is
+// complete until it has read the next token, which is on a line not yet
+// typed.
+func LineEnds() Option {
+	return func(o *options) {
+		o.lineEnds = true
+	}
+}
+
+// Parse starts the parse stage, which reads tokens from source and sends each
+// top-level statement on the returned channel. A lexer error is reported with
+// the syntax errors it causes. The channel is closed at the end of the input
+// or when ctx is done.
+func Parse(ctx context.Context, source *lexer.TokenSource) <-chan Statement {
+	statements := make(chan Statement)
+
+	go func() {
+		defer close(statements)
+
+		p := parser.New(source)
+		lexerErrorSent := false
+		for {
+			n := len(p.Errors)
+			node, ok := p.Next()
+			if !ok {
+				return
+			}
+
+			stmt := Statement{Node: node}
+			if len(p.Errors) > n {
+				stmt.Errors = append(stmt.Errors, p.Errors[n:]...)
+				if err := source.Err(); err != nil && !lexerErrorSent {
+					stmt.Errors = append([]string{err.Error()}, stmt.Errors...)
+					lexerErrorSent = true
+				}
+			}
+
+			select {
+			case statements <- stmt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return statements
+}
+
+// Execute starts the execute stage, which evaluates statements in env and
+// sends a Result for each. A statement with syntax errors is not evaluated;
+// its Result has a *ParseError. As in evaluator.Eval, a top-level return
+// statement ends the program. The returned channel is closed when statements
+// is, when the program ends, or when ctx is done. If Execute stops before
+// statements is closed, cancel ctx to stop the stages feeding it.
+func Execute(ctx context.Context, statements <-chan Statement, env *object.Environment, opts ...Option) <-chan Result {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	results := make(chan Result)
+
+	go func() {
+		defer close(results)
+
+		for {
+			var stmt Statement
+			select {
+			case s, ok := <-statements:
+				if !ok {
+					return
+				}
+				stmt = s
+			case <-ctx.Done():
+				return
+			}
+
+			result, done := execute(stmt, env)
+			done = done || (o.stopOnError && result.Err != nil)
+
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+			if done {
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+// execute evaluates stmt and reports whether it ends the program.
+func execute(stmt Statement, env *object.Environment) (Result, bool) {
+	result := Result{Statement: stmt.Node}
+	if len(stmt.Errors) > 0 {
+		result.Err = &ParseError{Errors: stmt.Errors}
+		return result, false
+	}
+
+	switch value := evaluator.Eval(stmt.Node, env).(type) {
+	case *object.ReturnValue:
+		result.Value = value.Value
+		return result, true
+	case *object.Error:
+		result.Err = errors.New(value.Message)
+	default:
+		result.Value = value
+	}
+	return result, false
+}
+
+// Run lexes, parses and executes the program read from r in env, and sends a
+// Result for each statement as soon as it has been executed. Cancel ctx to
+// stop the program early. The returned channel is closed once execution has
+// stopped; the lexer and parse stages follow as soon as any read from r they
+// are blocked in returns.
+func Run(ctx context.Context, r io.Reader, env *object.Environment, opts ...Option) <-chan Result {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var lexOpts []lexer.Option
+	if o.lineEnds {
+		lexOpts = append(lexOpts, lexer.WithLineEnds())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	_, tokens := lexer.NewReaderLexerContext(ctx, r, lexOpts...)
+	results := Execute(ctx, Parse(ctx, lexer.NewChannelSource(tokens)), env, opts...)
+
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		for result := range results {
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,236 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"monkey/object"
+)
+
+// verifyNoLeakedStages fails the test if a pipeline or lexer goroutine is
+// still running shortly after the test.
+func verifyNoLeakedStages(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		leaked := false
+		for _, stage := range []string{"lexer.(*Lexer).run", "pipeline.Parse.", "pipeline.Execute.", "pipeline.Run."} {
+			leaked = leaked || strings.Contains(stacks, stage)
+		}
+		if !leaked {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pipeline goroutines leaked:\n%s", stacks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// inspect describes a result as its value or its error.
+func inspect(result Result) string {
+	switch {
+	case result.Err != nil:
+		return "error: " + result.Err.Error()
+	case result.Value == nil:
+		return ""
+	default:
+		return result.Value.Inspect()
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let add = fn(x, y) { x + y }; add(1, 2); add(3, 4) * 2;",
+			[]string{"", "3", "14"},
+		},
+		{
+			"1 + true; 2;",
+			[]string{"error: type mismatch: INTEGER + BOOLEAN", "2"},
+		},
+		{
+			"let = 5; 1 + 1;",
+			[]string{
+				"error: expected next token to be Ident, got Assign instead",
+				"error: no prefix parse function for Assign found",
+				"5",
+				"2",
+			},
+		},
+		{
+			"1; return 2; 3;",
+			[]string{"1", "2"},
+		},
+		{
+			"1; 2 @ 3;",
+			[]string{
+				"1",
+				"2",
+				"error: unrecognized character in action: U+0040 '@'\nno prefix parse function for Illegal found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for result := range Run(context.Background(), strings.NewReader(tt.input), object.NewEnvironment()) {
+			got = append(got, inspect(result))
+		}
+
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%q: expected %q; got %q", tt.input, tt.expected, got)
+		}
+	}
+
+	verifyNoLeakedStages(t)
+}
+
+func TestRunStopOnError(t *testing.T) {
+	defer verifyNoLeakedStages(t)
+
+	env := object.NewEnvironment()
+	input := "let x = 1; x = 2; x + true; x = 3; puts(x);"
+
+	var got []string
+	for result := range Run(context.Background(), strings.NewReader(input), env, StopOnError()) {
+		got = append(got, inspect(result))
+	}
+
+	expected := []string{"", "2", "error: type mismatch: INTEGER + BOOLEAN"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %q; got %q", expected, got)
+	}
+	if x, _ := env.Get("x"); x.Inspect() != "2" {
+		t.Errorf("expected x to stay 2; got %s", x.Inspect())
+	}
+}
+
+func TestRunParseErrorType(t *testing.T) {
+	defer verifyNoLeakedStages(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	result := <-Run(ctx, strings.NewReader("let = 5;"), object.NewEnvironment())
+	var parseError *ParseError
+	if !errors.As(result.Err, &parseError) {
+		t.Fatalf("expected a *ParseError; got %v", result.Err)
+	}
+}
+
+func TestRunStreams(t *testing.T) {
+	defer verifyNoLeakedStages(t)
+
+	r, w := io.Pipe()
+	defer w.Close()
+	results := Run(context.Background(), r, object.NewEnvironment())
+
+	steps := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 2;\n", ""},
+		{"x * 3;\n", "6"},
+		{"let y = x + 1;\ny;\n", ""},
+	}
+	for _, step := range steps {
+		if _, err := io.WriteString(w, step.input); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case result := <-results:
+			if got := inspect(result); got != step.expected {
+				t.Errorf("after %q: expected %q; got %q", step.input, step.expected, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no result for %q before the input ended", step.input)
+		}
+	}
+
+	w.Close()
+	if got := inspect(<-results); got != "3" {
+		t.Errorf("expected %q; got %q", "3", got)
+	}
+	if result, ok := <-results; ok {
+		t.Errorf("expected the results to end; got %v", inspect(result))
+	}
+}
+
+func TestRunLineEnds(t *testing.T) {
+	defer verifyNoLeakedStages(t)
+
+	r, w := io.Pipe()
+	defer w.Close()
+	results := Run(context.Background(), r, object.NewEnvironment(), LineEnds())
+
+	// A line that leaves a statement unfinished gives no result yet.
+	steps := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 2\n", []string{""}},
+		{"x * 3\n", []string{"6"}},
+		{"let f = fn(a) {\n", nil},
+		{"a + x }\n", []string{""}},
+		{"f(\n1)\n", []string{"3"}},
+	}
+	for _, step := range steps {
+		if _, err := io.WriteString(w, step.input); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, expected := range step.expected {
+			select {
+			case result := <-results:
+				if got := inspect(result); got != expected {
+					t.Errorf("after %q: expected %q; got %q", step.input, expected, got)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("no result for %q before the next line", step.input)
+			}
+		}
+	}
+
+	// The end of the input ends the last statement too.
+	if _, err := io.WriteString(w, "x + 1"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if got := inspect(<-results); got != "3" {
+		t.Errorf("expected %q; got %q", "3", got)
+	}
+	if result, ok := <-results; ok {
+		t.Errorf("expected the results to end; got %v", inspect(result))
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	defer verifyNoLeakedStages(t)
+
+	r, w := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	results := Run(ctx, r, object.NewEnvironment())
+
+	go io.WriteString(w, "1;\n")
+	<-results
+	cancel()
+
+	for range results {
+	}
+
+	// The lexer is blocked reading the pipe until it is closed.
+	w.Close()
+}
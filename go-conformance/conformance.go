@@ -0,0 +1,291 @@
+// Package conformance runs the Go Monkey lexers and parsers in this
+// repository against a shared corpus, so that their behaviour can be
+// compared and divergences between them are visible.
+//
+// Each corpus file in testdata holds one case in a txtar-like format: an
+// optional comment, then sections that each start with a "-- name --" line.
+//
+//	-- input --         the Monkey source
+//	-- tokens --        the canonical token dump, see Dump
+//	-- sexpr --         the canonical parse, see ast.SExpr
+//	-- tokens:<impl> -- the token dump expected from one implementation
+//	-- sexpr:<impl> --  the parse expected from one implementation
+//
+// The canonical sections describe the reference implementation, go-devries.
+// A per-implementation section records a known divergence from them.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	deez "go_deez"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	concurrent "monkeylang/lexer"
+	concurrentparser "monkeylang/parser"
+)
+
+// Token is a token of any implementation, translated to the token types of
+// the reference implementation.
+type Token struct {
+	Type    lexer.TokenType
+	Literal string
+}
+
+// Dump writes tokens one per line as the type and quoted literal. The Eof
+// token is written without its literal, which varies between
+// implementations and carries no information.
+func Dump(tokens []Token) string {
+	var out strings.Builder
+	for _, tok := range tokens {
+		if tok.Type == lexer.Eof {
+			fmt.Fprintf(&out, "%s\n", tok.Type)
+		} else {
+			fmt.Fprintf(&out, "%s %q\n", tok.Type, tok.Literal)
+		}
+	}
+	return out.String()
+}
+
+// Implementation is one of the lexers, with its parser if it has one.
+type Implementation struct {
+	Name string
+
+	// Tokens returns every token of input, up to and including the Eof
+	// token if the lexer produces one.
+	Tokens func(input string) []Token
+
+	// Parse returns the S-expression of input, followed by a line for each
+	// parse error. It is nil for an implementation without a parser.
+	Parse func(input string) string
+}
+
+// Reference is the implementation the canonical sections describe.
+const Reference = "go-devries"
+
+// Implementations lists every implementation, the reference first.
+var Implementations = []Implementation{
+	{
+		Name: Reference,
+		Tokens: func(input string) []Token {
+			var tokens []Token
+			l := lexer.NewLexer(input)
+			for tok, ok := l.Next(); ok; tok, ok = l.Next() {
+				tokens = append(tokens, Token{tok.Type, tok.Literal})
+			}
+			return tokens
+		},
+		Parse: func(input string) string {
+			p := parser.New(lexer.NewLexer(input))
+			return sexpr(p.ParseProgram(), p.Errors)
+		},
+	},
+	{
+		Name: "go-concurrent",
+		Tokens: func(input string) []Token {
+			var tokens []Token
+			_, ch := concurrent.NewLexerContext(context.Background(), input)
+			for tok := range ch {
+				tokens = append(tokens, Token{concurrentTypes[tok.Type], tok.Value})
+			}
+			return tokens
+		},
+		Parse: func(input string) string {
+			program, errors := concurrentparser.Parse(context.Background(), input)
+			return sexpr(program, errors)
+		},
+	},
+	{
+		Name: "go",
+		Tokens: func(input string) []Token {
+			var tokens []Token
+			tokenizer := deez.NewTokenizer(input)
+			for tok, ok := tokenizer.Next(); ok; tok, ok = tokenizer.Next() {
+				tokens = append(tokens, Token{deezTypes[tok.Type()], tok.Literal()})
+			}
+			return tokens
+		},
+	},
+}
+
+func sexpr(program *ast.Program, errors []string) string {
+	var out strings.Builder
+	if s := ast.SExpr(program); s != "" {
+		out.WriteString(s + "\n")
+	}
+	for _, msg := range errors {
+		out.WriteString("error: " + msg + "\n")
+	}
+	return out.String()
+}
+
+var concurrentTypes = map[concurrent.TokenType]lexer.TokenType{
+	concurrent.TokenIllegal:     lexer.Illegal,
+	concurrent.TokenEOF:         lexer.Eof,
+	concurrent.TokenIdent:       lexer.Ident,
+	concurrent.TokenInt:         lexer.Int,
+	concurrent.TokenAssign:      lexer.Assign,
+	concurrent.TokenPlus:        lexer.Plus,
+	concurrent.TokenMinus:       lexer.Minus,
+	concurrent.TokenBang:        lexer.Bang,
+	concurrent.TokenAsterisk:    lexer.Asterisk,
+	concurrent.TokenSlash:       lexer.ForwardSlash,
+	concurrent.TokenLessThan:    lexer.LessThan,
+	concurrent.TokenGreaterThan: lexer.GreaterThan,
+	concurrent.TokenEqual:       lexer.Equal,
+	concurrent.TokenNotEqual:    lexer.NotEqual,
+	concurrent.TokenComma:       lexer.Comma,
+	concurrent.TokenSemicolon:   lexer.Semicolon,
+	concurrent.TokenLParen:      lexer.LParen,
+	concurrent.TokenRParen:      lexer.RParen,
+	concurrent.TokenLSquirly:    lexer.LSquirly,
+	concurrent.TokenRSquirly:    lexer.RSquirly,
+	concurrent.TokenFunction:    lexer.Function,
+	concurrent.TokenLet:         lexer.Let,
+	concurrent.TokenTrue:        lexer.True,
+	concurrent.TokenFalse:       lexer.False,
+	concurrent.TokenIf:          lexer.If,
+	concurrent.TokenElse:        lexer.Else,
+	concurrent.TokenReturn:      lexer.Return,
+}
+
+var deezTypes = map[deez.TokenType]lexer.TokenType{
+	deez.Illegal:     lexer.Illegal,
+	deez.Eof:         lexer.Eof,
+	deez.Ident:       lexer.Ident,
+	deez.Int:         lexer.Int,
+	deez.Equal:       lexer.Assign,
+	deez.Plus:        lexer.Plus,
+	deez.Minus:       lexer.Minus,
+	deez.Bang:        lexer.Bang,
+	deez.Asterisk:    lexer.Asterisk,
+	deez.Slash:       lexer.ForwardSlash,
+	deez.LessThan:    lexer.LessThan,
+	deez.GreaterThan: lexer.GreaterThan,
+	deez.Eq:          lexer.Equal,
+	deez.NotEq:       lexer.NotEqual,
+	deez.Comma:       lexer.Comma,
+	deez.Semicolon:   lexer.Semicolon,
+	deez.LParen:      lexer.LParen,
+	deez.RParen:      lexer.RParen,
+	deez.LSquirly:    lexer.LSquirly,
+	deez.RSquirly:    lexer.RSquirly,
+	deez.Function:    lexer.Function,
+	deez.Let:         lexer.Let,
+	deez.True:        lexer.True,
+	deez.False:       lexer.False,
+	deez.If:          lexer.If,
+	deez.Else:        lexer.Else,
+	deez.Return:      lexer.Return,
+}
+
+// Case is one entry of the corpus.
+type Case struct {
+	// Comment is the text before the first section.
+	Comment string
+
+	// Sections maps each section name to its content, in which every line
+	// ends with a newline.
+	Sections map[string]string
+
+	// Order lists the section names as they appear in the file.
+	Order []string
+}
+
+// ParseCase parses a corpus file.
+func ParseCase(data []byte) (*Case, error) {
+	c := &Case{Sections: map[string]string{}}
+
+	name := ""
+	var content strings.Builder
+	flush := func() {
+		if name == "" {
+			c.Comment = content.String()
+		} else {
+			c.Sections[name] = content.String()
+		}
+		content.Reset()
+	}
+
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if header, ok := sectionName(string(line)); ok {
+			flush()
+			if _, dup := c.Sections[header]; dup {
+				return nil, fmt.Errorf("duplicate section %q", header)
+			}
+			name = header
+			c.Sections[name] = ""
+			c.Order = append(c.Order, name)
+			continue
+		}
+		content.Write(line)
+		if line[len(line)-1] != '\n' {
+			content.WriteByte('\n')
+		}
+	}
+	flush()
+
+	if _, ok := c.Sections["input"]; !ok {
+		return nil, fmt.Errorf("missing input section")
+	}
+	return c, nil
+}
+
+func sectionName(line string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "-- ") || !strings.HasSuffix(line, " --") || len(line) < 7 {
+		return "", false
+	}
+	return strings.TrimSpace(line[3 : len(line)-3]), true
+}
+
+// Expected returns the expected content of section ("tokens" or "sexpr") for
+// the named implementation, and whether it is a known divergence from the
+// canonical section. It returns false for ok if neither is present.
+func (c *Case) Expected(section, impl string) (content string, divergent, ok bool) {
+	if content, ok := c.Sections[section+":"+impl]; ok {
+		return content, true, true
+	}
+	content, ok = c.Sections[section]
+	return content, false, ok
+}
+
+// Set replaces the content of a section, adding it at the end if it is new.
+func (c *Case) Set(name, content string) {
+	if _, ok := c.Sections[name]; !ok {
+		c.Order = append(c.Order, name)
+	}
+	c.Sections[name] = content
+}
+
+// Delete removes a section.
+func (c *Case) Delete(name string) {
+	if _, ok := c.Sections[name]; !ok {
+		return
+	}
+	delete(c.Sections, name)
+	for i, n := range c.Order {
+		if n == name {
+			c.Order = append(c.Order[:i], c.Order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Format returns the case in the corpus file format.
+func (c *Case) Format() []byte {
+	var out bytes.Buffer
+	out.WriteString(c.Comment)
+	for _, name := range c.Order {
+		fmt.Fprintf(&out, "-- %s --\n", name)
+		out.WriteString(c.Sections[name])
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,217 @@
+package conformance
+
+import (
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"monkey/astgen"
+	"monkey/lexer"
+	"monkey/printer"
+)
+
+var update = flag.Bool("update", false, "rewrite the corpus from the current output of each implementation")
+
+func TestConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("empty corpus")
+	}
+
+	for _, file := range files {
+		file := file
+		name := strings.TrimSuffix(filepath.Base(file), ".txt")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := ParseCase(data)
+			if err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+
+			if *update {
+				updateCase(c)
+				if err := os.WriteFile(file, c.Format(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			input := c.Sections["input"]
+			for _, impl := range Implementations {
+				check(t, c, "tokens", impl.Name, func() string { return Dump(impl.Tokens(input)) })
+				if impl.Parse != nil {
+					check(t, c, "sexpr", impl.Name, func() string { return impl.Parse(input) })
+				}
+			}
+		})
+	}
+}
+
+func check(t *testing.T, c *Case, section, impl string, output func() string) {
+	t.Helper()
+
+	want, divergent, ok := c.Expected(section, impl)
+	if !ok {
+		return
+	}
+	got := output()
+	if got != want {
+		t.Errorf("%s %s:\ngot:\n%swant:\n%s", impl, section, got, want)
+		return
+	}
+	if divergent {
+		t.Logf("%s %s diverges from %s:\n%s", impl, section, Reference, got)
+	}
+}
+
+// updateCase sets the canonical sections from the reference implementation
+// and records a divergence section for every other implementation whose
+// output differs from it. Sections absent from the case stay absent.
+func updateCase(c *Case) {
+	input := c.Sections["input"]
+	for _, impl := range Implementations {
+		outputs := map[string]func(string) string{
+			"tokens": func(input string) string { return Dump(impl.Tokens(input)) },
+		}
+		if impl.Parse != nil {
+			outputs["sexpr"] = impl.Parse
+		}
+		for _, section := range []string{"tokens", "sexpr"} {
+			if _, ok := c.Sections[section]; !ok {
+				continue
+			}
+			output, ok := outputs[section]
+			if !ok {
+				continue
+			}
+			got := output(input)
+			if impl.Name == Reference {
+				c.Set(section, got)
+			} else if got == c.Sections[section] {
+				c.Delete(section + ":" + impl.Name)
+			} else {
+				c.Set(section+":"+impl.Name, got)
+			}
+		}
+	}
+}
+
+// TestGeneratedPrograms compares every implementation with the reference
+// on random programs in the language they all support.
+func TestGeneratedPrograms(t *testing.T) {
+	g := astgen.New(rand.New(rand.NewSource(1)), astgen.Basic())
+	reference := Implementations[0]
+
+	for i := 0; i < 500; i++ {
+		input := printer.String(g.Program())
+		tokens := Dump(reference.Tokens(input))
+		sexpr := reference.Parse(input)
+
+		for _, impl := range Implementations[1:] {
+			if got := Dump(impl.Tokens(input)); got != tokens {
+				t.Fatalf("%s tokens differ on:\n%s\ngot:\n%swant:\n%s", impl.Name, input, got, tokens)
+			}
+			if impl.Parse == nil {
+				continue
+			}
+			if got := impl.Parse(input); got != sexpr {
+				t.Fatalf("%s parse differs on:\n%s\ngot:\n%swant:\n%s", impl.Name, input, got, sexpr)
+			}
+		}
+	}
+}
+
+// TestCharacterClasses checks that the go implementation agrees with the
+// reference on which characters start or continue an identifier and which
+// are whitespace, so that its copy of the Unicode tables cannot drift from
+// go-devries/lexer/ident.go. go-concurrent calls the reference's functions
+// and has no copy of its own.
+func TestCharacterClasses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("lexes every Unicode character")
+	}
+
+	failures := 0
+	for r := rune(0); r <= unicode.MaxRune && failures < 10; r++ {
+		if !utf8.ValidRune(r) {
+			continue
+		}
+		want := characterClass(Implementations[0], r)
+		for _, impl := range Implementations[1:] {
+			if impl.Name == "go-concurrent" {
+				continue
+			}
+			if got := characterClass(impl, r); got != want {
+				t.Errorf("%s: %U is %q, want %q", impl.Name, r, got, want)
+				failures++
+			}
+		}
+	}
+}
+
+// characterClass reports how impl lexes r: as "identifier start",
+// "identifier" for a character only allowed after the first, "whitespace",
+// or "" for anything else.
+func characterClass(impl Implementation, r rune) string {
+	c := string(r)
+	if tokens := impl.Tokens(c + "a"); isIdent(tokens, 0, c+"a") {
+		return "identifier start"
+	}
+	tokens := impl.Tokens("a" + c + "a")
+	switch {
+	case isIdent(tokens, 0, "a"+c+"a"):
+		return "identifier"
+	case isIdent(tokens, 0, "a") && isIdent(tokens, 1, "a"):
+		return "whitespace"
+	}
+	return ""
+}
+
+func isIdent(tokens []Token, i int, literal string) bool {
+	return i < len(tokens) && tokens[i].Type == lexer.Ident && tokens[i].Literal == literal
+}
+
+func TestParseCase(t *testing.T) {
+	data := "A comment.\n-- input --\nlet x = 1;\n-- tokens --\nEof\n-- tokens:go --\nIllegal \"x\""
+
+	c, err := ParseCase([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Comment != "A comment.\n" {
+		t.Errorf("comment = %q", c.Comment)
+	}
+	if got := c.Sections["input"]; got != "let x = 1;\n" {
+		t.Errorf("input = %q", got)
+	}
+
+	if got, divergent, ok := c.Expected("tokens", "go"); !ok || !divergent || got != "Illegal \"x\"\n" {
+		t.Errorf("Expected(tokens, go) = %q, %t, %t", got, divergent, ok)
+	}
+	if got, divergent, ok := c.Expected("tokens", "go-concurrent"); !ok || divergent || got != "Eof\n" {
+		t.Errorf("Expected(tokens, go-concurrent) = %q, %t, %t", got, divergent, ok)
+	}
+	if _, _, ok := c.Expected("sexpr", "go"); ok {
+		t.Errorf("Expected(sexpr, go) found a missing section")
+	}
+
+	want := data + "\n"
+	if got := string(c.Format()); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	if _, err := ParseCase([]byte("-- tokens --\nEof\n")); err == nil {
+		t.Errorf("expected an error for a case without input")
+	}
+}
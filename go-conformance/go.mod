@@ -0,0 +1,15 @@
+module conformance
+
+go 1.20
+
+require (
+	go_deez v0.0.0
+	monkey v0.0.0
+	monkeylang v0.0.0
+)
+
+replace (
+	go_deez => ../go
+	monkey => ../go-devries
+	monkeylang => ../go-concurrent
+)
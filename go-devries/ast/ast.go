@@ -2,7 +2,10 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
+	"monkey/diag"
 	"monkey/lexer"
+	"reflect"
 	"strings"
 )
 
@@ -37,7 +40,7 @@ func (p *Program) String() string {
 	var out bytes.Buffer
 
 	for _, s := range p.Statements {
-		out.WriteString(s.String())
+		out.WriteString(nodeString(s))
 	}
 
 	return out.String()
@@ -58,12 +61,9 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(nodeString(ls.Name))
 	out.WriteString(" = ")
-
-	if ls.Value != nil {
-		out.WriteString(ls.Value.String())
-	}
+	out.WriteString(nodeString(ls.Value))
 	out.WriteString(";")
 
 	return out.String()
@@ -72,6 +72,11 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token lexer.Token
 	Value string
+
+	// Span is where the identifier is in the source. It is only set by
+	// the parser, and only when it reads from a lexer that knows; the zero
+	// Span refers to no place.
+	Span diag.Span
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -87,6 +92,25 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// BigIntegerLiteral is an integer literal too large for an int64.
+type BigIntegerLiteral struct {
+	Token lexer.Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) expressionNode()      {}
+func (bl *BigIntegerLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BigIntegerLiteral) String() string       { return bl.Token.Literal }
+
+type FloatLiteral struct {
+	Token lexer.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type ReturnStatement struct {
 	Token       lexer.Token
 	ReturnValue Expression
@@ -100,9 +124,7 @@ func (rs *ReturnStatement) String() string {
 
 	out.WriteString(rs.TokenLiteral() + " ")
 
-	if rs.ReturnValue != nil {
-		out.WriteString(rs.ReturnValue.String())
-	}
+	out.WriteString(nodeString(rs.ReturnValue))
 	out.WriteString(";")
 
 	return out.String()
@@ -118,11 +140,7 @@ func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 
 func (es *ExpressionStatement) String() string {
-	if es.Expression != nil {
-		return es.Expression.String()
-	}
-
-	return ""
+	return nodeString(es.Expression)
 }
 
 type PrefixExpression struct {
@@ -138,7 +156,7 @@ func (pe *PrefixExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
-	out.WriteString(pe.Right.String())
+	out.WriteString(nodeString(pe.Right))
 	out.WriteString(")")
 
 	return out.String()
@@ -157,9 +175,9 @@ func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
-	out.WriteString(ie.Left.String())
+	out.WriteString(nodeString(ie.Left))
 	out.WriteString(" " + ie.Operator + " ")
-	out.WriteString(ie.Right.String())
+	out.WriteString(nodeString(ie.Right))
 	out.WriteString(")")
 
 	return out.String()
@@ -185,7 +203,7 @@ func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
 	for _, s := range bs.Statements {
-		out.WriteString(s.String())
+		out.WriteString(nodeString(s))
 	}
 
 	return out.String()
@@ -204,12 +222,12 @@ func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("if ")
-	out.WriteString(ie.Condition.String())
+	out.WriteString(nodeString(ie.Condition))
 	out.WriteString(" ")
-	out.WriteString(ie.Consequence.String())
+	out.WriteString(nodeString(ie.Consequence))
 	if ie.Alternative != nil {
 		out.WriteString("else ")
-		out.WriteString(ie.Alternative.String())
+		out.WriteString(nodeString(ie.Alternative))
 	}
 
 	return out.String()
@@ -228,14 +246,14 @@ func (fl *FunctionLiteral) String() string {
 
 	params := []string{}
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		params = append(params, nodeString(p))
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
+	out.WriteString(nodeString(fl.Body))
 
 	return out.String()
 }
@@ -253,13 +271,146 @@ func (ce *CallExpression) String() string {
 
 	args := []string{}
 	for _, a := range ce.Arguments {
-		args = append(args, a.String())
+		args = append(args, nodeString(a))
 	}
 
-	out.WriteString(ce.Function.String())
+	out.WriteString(nodeString(ce.Function))
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 
 	return out.String()
 }
+
+type ArrayLiteral struct {
+	Token    lexer.Token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, nodeString(el))
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token lexer.Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(nodeString(ie.Left))
+	out.WriteString("[")
+	out.WriteString(nodeString(ie.Index))
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// AssignExpression rebinds an existing name or array element. Operator is
+// one of "=", "+=", "-=", "*=" or "/=" and Target is either an *Identifier
+// or an *IndexExpression.
+type AssignExpression struct {
+	Token    lexer.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(nodeString(ae.Target))
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(nodeString(ae.Value))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type StringLiteral struct {
+	Token lexer.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return `"` + EscapeString(sl.Value) + `"` }
+
+// TemplateLiteral is a string with embedded expressions. Strings holds the
+// text around the expressions, so it always has one more element than
+// Expressions: "a${x}b" has Strings ["a", "b"] and Expressions [x].
+type TemplateLiteral struct {
+	Token       lexer.Token
+	Strings     []string
+	Expressions []Expression
+}
+
+func (tl *TemplateLiteral) expressionNode()      {}
+func (tl *TemplateLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+	for i, s := range tl.Strings {
+		out.WriteString(EscapeString(s))
+		if i < len(tl.Expressions) {
+			out.WriteString("${")
+			out.WriteString(nodeString(tl.Expressions[i]))
+			out.WriteString("}")
+		}
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
+
+var stringEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\t", `\t`,
+	"\r", `\r`,
+	"${", `\${`,
+)
+
+// EscapeString quotes s the way the lexer expects string literals to be
+// written, so that String() output lexes back to the same value.
+func EscapeString(s string) string {
+	return stringEscaper.Replace(s)
+}
+
+// nodeString returns node.String(), or "" for a missing node. A program with
+// syntax errors has missing nodes wherever a part of it failed to parse.
+func nodeString(node Node) string {
+	if isNil(node) {
+		return ""
+	}
+	return node.String()
+}
+
+// isNil reports whether node is missing, either as a nil interface or as a
+// nil pointer of one of the node types.
+func isNil(node Node) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
@@ -0,0 +1,115 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SExpr returns node as an S-expression that spells out the structure of
+// the tree, for comparing parsers and for test expectations:
+//
+//	let x = a + b * 2;   =>   (let x (+ a (* b 2)))
+//
+// A program has one S-expression per statement, separated by newlines.
+// Missing nodes, as left behind by a parse error, are written as <nil>.
+func SExpr(node Node) string {
+	var out strings.Builder
+	writeSExpr(&out, node)
+	return out.String()
+}
+
+func writeSExpr(out *strings.Builder, node Node) {
+	if isNil(node) {
+		out.WriteString("<nil>")
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for i, s := range node.Statements {
+			if i > 0 {
+				out.WriteString("\n")
+			}
+			writeSExpr(out, s)
+		}
+	case *LetStatement:
+		writeList(out, "let", node.Name, node.Value)
+	case *ReturnStatement:
+		writeList(out, "return", node.ReturnValue)
+	case *ExpressionStatement:
+		writeSExpr(out, node.Expression)
+	case *BlockStatement:
+		nodes := make([]Node, len(node.Statements))
+		for i, s := range node.Statements {
+			nodes[i] = s
+		}
+		writeList(out, "block", nodes...)
+	case *Identifier:
+		out.WriteString(node.Value)
+	case *IntegerLiteral:
+		fmt.Fprint(out, node.Value)
+	case *BigIntegerLiteral:
+		out.WriteString(node.Value.String())
+	case *FloatLiteral:
+		fmt.Fprint(out, node.Value)
+	case *Boolean:
+		fmt.Fprint(out, node.Value)
+	case *StringLiteral:
+		fmt.Fprintf(out, "%q", node.Value)
+	case *TemplateLiteral:
+		out.WriteString("(template")
+		for i, s := range node.Strings {
+			fmt.Fprintf(out, " %q", s)
+			if i < len(node.Expressions) {
+				out.WriteString(" ")
+				writeSExpr(out, node.Expressions[i])
+			}
+		}
+		out.WriteString(")")
+	case *PrefixExpression:
+		writeList(out, node.Operator, node.Right)
+	case *InfixExpression:
+		writeList(out, node.Operator, node.Left, node.Right)
+	case *AssignExpression:
+		writeList(out, node.Operator, node.Target, node.Value)
+	case *IfExpression:
+		if node.Alternative == nil {
+			writeList(out, "if", node.Condition, node.Consequence)
+		} else {
+			writeList(out, "if", node.Condition, node.Consequence, node.Alternative)
+		}
+	case *FunctionLiteral:
+		params := make([]string, len(node.Parameters))
+		for i, p := range node.Parameters {
+			params[i] = SExpr(p)
+		}
+		out.WriteString("(fn (" + strings.Join(params, " ") + ") ")
+		writeSExpr(out, node.Body)
+		out.WriteString(")")
+	case *CallExpression:
+		writeList(out, "call", append([]Node{node.Function}, expressionNodes(node.Arguments)...)...)
+	case *ArrayLiteral:
+		writeList(out, "array", expressionNodes(node.Elements)...)
+	case *IndexExpression:
+		writeList(out, "index", node.Left, node.Index)
+	default:
+		fmt.Fprintf(out, "<%T>", node)
+	}
+}
+
+func writeList(out *strings.Builder, head string, nodes ...Node) {
+	out.WriteString("(" + head)
+	for _, n := range nodes {
+		out.WriteString(" ")
+		writeSExpr(out, n)
+	}
+	out.WriteString(")")
+}
+
+func expressionNodes(exprs []Expression) []Node {
+	nodes := make([]Node, len(exprs))
+	for i, e := range exprs {
+		nodes[i] = e
+	}
+	return nodes
+}
@@ -0,0 +1,91 @@
+package ast
+
+// A Visitor's Visit method is called by Walk for each node it meets. If it
+// returns a non-nil Visitor w, Walk visits each of the node's children with
+// w and then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node depth first, visiting the children
+// of each node in the order they appear in the source. Missing nodes, as
+// left behind by a parse error, are skipped.
+func Walk(v Visitor, node Node) {
+	if isNil(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		walkStatements(v, node.Statements)
+	case *LetStatement:
+		Walk(v, node.Name)
+		Walk(v, node.Value)
+	case *ReturnStatement:
+		Walk(v, node.ReturnValue)
+	case *ExpressionStatement:
+		Walk(v, node.Expression)
+	case *BlockStatement:
+		walkStatements(v, node.Statements)
+	case *TemplateLiteral:
+		walkExpressions(v, node.Expressions)
+	case *PrefixExpression:
+		Walk(v, node.Right)
+	case *InfixExpression:
+		Walk(v, node.Left)
+		Walk(v, node.Right)
+	case *AssignExpression:
+		Walk(v, node.Target)
+		Walk(v, node.Value)
+	case *IfExpression:
+		Walk(v, node.Condition)
+		Walk(v, node.Consequence)
+		Walk(v, node.Alternative)
+	case *FunctionLiteral:
+		for _, p := range node.Parameters {
+			Walk(v, p)
+		}
+		Walk(v, node.Body)
+	case *CallExpression:
+		Walk(v, node.Function)
+		walkExpressions(v, node.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, node.Elements)
+	case *IndexExpression:
+		Walk(v, node.Left)
+		Walk(v, node.Index)
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, s := range statements {
+		Walk(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, expressions []Expression) {
+	for _, e := range expressions {
+		Walk(v, e)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node as Walk does, calling f for
+// each node. If f returns true, Inspect goes on to the node's children and
+// then calls f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,82 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func TestInspect(t *testing.T) {
+	input := `let a = fn(b, c) { if (!b) { return c } else { [b, c][0] += 1.5 } };
+puts(a(2, 9999999999999999999), "${x} and ${true}");`
+	p := parser.New(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors) > 0 {
+		t.Fatalf("parse errors %q", p.Errors)
+	}
+
+	var visited []string
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		depth++
+		name := fmt.Sprintf("%T", node)[len("*ast."):]
+		if ident, ok := node.(*ast.Identifier); ok {
+			name = ident.Value
+		}
+		visited = append(visited, name)
+		return true
+	})
+
+	expected := "Program LetStatement a FunctionLiteral b c BlockStatement ExpressionStatement " +
+		"IfExpression PrefixExpression b BlockStatement ReturnStatement c BlockStatement " +
+		"ExpressionStatement AssignExpression IndexExpression ArrayLiteral b c IntegerLiteral FloatLiteral " +
+		"ExpressionStatement CallExpression puts CallExpression a IntegerLiteral BigIntegerLiteral " +
+		"TemplateLiteral x Boolean"
+	if got := strings.Join(visited, " "); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+	if depth != 0 {
+		t.Errorf("expected a nil call after each node's children, off by %d", depth)
+	}
+}
+
+func TestInspectPrunes(t *testing.T) {
+	p := parser.New(lexer.NewLexer("let f = fn(x) { y }; z"))
+	program := p.ParseProgram()
+
+	var idents []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		_, fn := node.(*ast.FunctionLiteral)
+		return !fn
+	})
+
+	if got := strings.Join(idents, " "); got != "f z" {
+		t.Errorf("expected the function literal to be skipped, got %q", got)
+	}
+}
+
+func TestWalkSkipsMissingNodes(t *testing.T) {
+	p := parser.New(lexer.NewLexer("let = 1; if (x) { y"))
+	program := p.ParseProgram()
+	if len(p.Errors) == 0 {
+		t.Fatalf("expected parse errors")
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			node.TokenLiteral()
+		}
+		return true
+	})
+}
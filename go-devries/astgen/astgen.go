@@ -0,0 +1,468 @@
+// Package astgen generates random, syntactically valid Monkey programs for
+// property and differential testing. A generated tree has every token set
+// the way the parser would set it, so it can be printed with the printer
+// package and compared with the tree parsed back from the output.
+package astgen
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strconv"
+
+	"monkey/ast"
+	"monkey/lexer"
+)
+
+// Config bounds the size of the programs a Generator produces and selects
+// the parts of the language they use.
+type Config struct {
+	// MaxStatements is the most statements in the program and in each block.
+	MaxStatements int
+
+	// MaxDepth is the deepest expressions and blocks nest.
+	MaxDepth int
+
+	PrefixOperators []string
+	InfixOperators  []string
+
+	// Assignments allows plain and compound assignment expressions.
+	Assignments bool
+
+	// Arrays allows array literals and index expressions.
+	Arrays bool
+
+	// Strings allows string literals and templates.
+	Strings bool
+
+	// Numbers allows floats, big integers and integers in other bases or
+	// with digit separators, besides small decimal integers.
+	Numbers bool
+}
+
+// Full returns a configuration that uses everything the parser supports.
+func Full() Config {
+	return Config{
+		MaxStatements:   5,
+		MaxDepth:        5,
+		PrefixOperators: []string{"!", "-", "~"},
+		InfixOperators: []string{
+			"||", "&&", "==", "!=", "<", ">", "<=", ">=", "|", "^", "&",
+			"<<", ">>", "+", "-", "*", "/", "%", "**",
+		},
+		Assignments: true,
+		Arrays:      true,
+		Strings:     true,
+		Numbers:     true,
+	}
+}
+
+// Basic returns a configuration limited to the language of the book's
+// parser chapter, which every Monkey implementation supports.
+func Basic() Config {
+	return Config{
+		MaxStatements:   5,
+		MaxDepth:        5,
+		PrefixOperators: []string{"!", "-"},
+		InfixOperators:  []string{"==", "!=", "<", ">", "+", "-", "*", "/"},
+	}
+}
+
+var tokenTypes = map[string]lexer.TokenType{
+	"!":  lexer.Bang,
+	"~":  lexer.Tilde,
+	"||": lexer.Or,
+	"&&": lexer.And,
+	"==": lexer.Equal,
+	"!=": lexer.NotEqual,
+	"<":  lexer.LessThan,
+	">":  lexer.GreaterThan,
+	"<=": lexer.LessEqual,
+	">=": lexer.GreaterEqual,
+	"|":  lexer.Pipe,
+	"^":  lexer.Caret,
+	"&":  lexer.Ampersand,
+	"<<": lexer.ShiftLeft,
+	">>": lexer.ShiftRight,
+	"+":  lexer.Plus,
+	"-":  lexer.Minus,
+	"*":  lexer.Asterisk,
+	"/":  lexer.ForwardSlash,
+	"%":  lexer.Percent,
+	"**": lexer.Power,
+	"=":  lexer.Assign,
+	"+=": lexer.PlusAssign,
+	"-=": lexer.MinusAssign,
+	"*=": lexer.AsteriskAssign,
+	"/=": lexer.ForwardSlashAssign,
+}
+
+var assignOperators = []string{"=", "+=", "-=", "*=", "/="}
+
+// Generator produces random programs. Names are mostly drawn from the
+// bindings in scope, so that the programs are not only valid syntax but
+// also tend to evaluate without unknown identifiers.
+type Generator struct {
+	rand   *rand.Rand
+	config Config
+
+	// scopes holds the names bound by let statements and parameters in
+	// each enclosing block, the innermost last.
+	scopes [][]string
+	names  int
+
+	// functions counts the enclosing function literals.
+	functions int
+}
+
+// New returns a generator that draws its choices from r, so that the same
+// seed produces the same programs.
+func New(r *rand.Rand, config Config) *Generator {
+	return &Generator{rand: r, config: config}
+}
+
+// Program returns a new random program.
+func (g *Generator) Program() *ast.Program {
+	g.scopes = [][]string{nil}
+	g.names = 0
+	g.functions = 0
+
+	program := &ast.Program{Statements: []ast.Statement{}}
+	for n := g.rand.Intn(g.config.MaxStatements + 1); n > 0; n-- {
+		program.Statements = append(program.Statements, g.statement(0))
+	}
+	return program
+}
+
+func (g *Generator) statement(depth int) ast.Statement {
+	switch g.rand.Intn(4) {
+	case 0, 1:
+		value := g.expression(depth)
+		name := g.newName("x")
+		return &ast.LetStatement{
+			Token: lexer.Token{Type: lexer.Let, Literal: "let"},
+			Name:  identifier(name),
+			Value: value,
+		}
+	case 2:
+		if g.functions > 0 {
+			return &ast.ReturnStatement{
+				Token:       lexer.Token{Type: lexer.Return, Literal: "return"},
+				ReturnValue: g.expression(depth),
+			}
+		}
+	}
+
+	expr := g.expression(depth)
+	return &ast.ExpressionStatement{Token: firstToken(expr), Expression: expr}
+}
+
+func (g *Generator) block(depth int) *ast.BlockStatement {
+	block := &ast.BlockStatement{
+		Token:      lexer.Token{Type: lexer.LSquirly, Literal: "{"},
+		Statements: []ast.Statement{},
+	}
+
+	g.scopes = append(g.scopes, nil)
+	for n := g.rand.Intn(g.config.MaxStatements + 1); n > 0; n-- {
+		block.Statements = append(block.Statements, g.statement(depth))
+	}
+	g.scopes = g.scopes[:len(g.scopes)-1]
+
+	return block
+}
+
+func (g *Generator) expression(depth int) ast.Expression {
+	if depth >= g.config.MaxDepth || g.rand.Intn(g.config.MaxDepth+1) <= depth {
+		return g.leaf()
+	}
+	depth++
+
+	for {
+		switch g.rand.Intn(10) {
+		case 0:
+			if len(g.config.PrefixOperators) == 0 {
+				continue
+			}
+			op := g.choose(g.config.PrefixOperators)
+			return &ast.PrefixExpression{
+				Token:    operatorToken(op),
+				Operator: op,
+				Right:    g.expression(depth),
+			}
+		case 1, 2:
+			if len(g.config.InfixOperators) == 0 {
+				continue
+			}
+			op := g.choose(g.config.InfixOperators)
+			return &ast.InfixExpression{
+				Token:    operatorToken(op),
+				Left:     g.expression(depth),
+				Operator: op,
+				Right:    g.expression(depth),
+			}
+		case 3:
+			expr := &ast.IfExpression{
+				Token:       lexer.Token{Type: lexer.If, Literal: "if"},
+				Condition:   g.expression(depth),
+				Consequence: g.block(depth),
+			}
+			if g.rand.Intn(2) == 0 {
+				expr.Alternative = g.block(depth)
+			}
+			return expr
+		case 4:
+			return g.function(depth)
+		case 5:
+			return &ast.CallExpression{
+				Token:     lexer.Token{Type: lexer.LParen, Literal: "("},
+				Function:  g.callee(depth),
+				Arguments: g.expressions(depth),
+			}
+		case 6:
+			if !g.config.Arrays {
+				continue
+			}
+			return &ast.ArrayLiteral{
+				Token:    lexer.Token{Type: lexer.LBracket, Literal: "["},
+				Elements: g.expressions(depth),
+			}
+		case 7:
+			if !g.config.Arrays {
+				continue
+			}
+			return g.index(depth)
+		case 8:
+			if !g.config.Assignments {
+				continue
+			}
+			var target ast.Expression = identifier(g.name())
+			if g.config.Arrays && g.rand.Intn(2) == 0 {
+				target = g.index(depth)
+			}
+			op := g.choose(assignOperators)
+			return &ast.AssignExpression{
+				Token:    operatorToken(op),
+				Target:   target,
+				Operator: op,
+				Value:    g.expression(depth),
+			}
+		case 9:
+			if !g.config.Strings {
+				continue
+			}
+			return g.template(depth)
+		}
+	}
+}
+
+func (g *Generator) expressions(depth int) []ast.Expression {
+	exprs := []ast.Expression{}
+	for n := g.rand.Intn(4); n > 0; n-- {
+		exprs = append(exprs, g.expression(depth))
+	}
+	return exprs
+}
+
+func (g *Generator) function(depth int) *ast.FunctionLiteral {
+	fn := &ast.FunctionLiteral{
+		Token:      lexer.Token{Type: lexer.Function, Literal: "fn"},
+		Parameters: []*ast.Identifier{},
+	}
+
+	// The parameters are bound in a scope around the body's.
+	g.scopes = append(g.scopes, nil)
+	g.functions++
+	for n := g.rand.Intn(4); n > 0; n-- {
+		fn.Parameters = append(fn.Parameters, identifier(g.newName("p")))
+	}
+	fn.Body = g.block(depth)
+	g.functions--
+	g.scopes = g.scopes[:len(g.scopes)-1]
+
+	return fn
+}
+
+// callee returns an expression to call: usually a name, sometimes a
+// function literal or any other expression.
+func (g *Generator) callee(depth int) ast.Expression {
+	switch g.rand.Intn(4) {
+	case 0:
+		return g.function(depth)
+	case 1:
+		return g.expression(depth)
+	default:
+		return identifier(g.name())
+	}
+}
+
+func (g *Generator) index(depth int) *ast.IndexExpression {
+	return &ast.IndexExpression{
+		Token: lexer.Token{Type: lexer.LBracket, Literal: "["},
+		Left:  g.expression(depth),
+		Index: g.expression(depth),
+	}
+}
+
+func (g *Generator) template(depth int) *ast.TemplateLiteral {
+	n := 1 + g.rand.Intn(3)
+	template := &ast.TemplateLiteral{Strings: []string{g.text()}}
+	template.Token = lexer.Token{Type: lexer.TemplateHead, Literal: template.Strings[0]}
+	for i := 0; i < n; i++ {
+		template.Expressions = append(template.Expressions, g.expression(depth))
+		template.Strings = append(template.Strings, g.text())
+	}
+	return template
+}
+
+func (g *Generator) leaf() ast.Expression {
+	for {
+		switch g.rand.Intn(5) {
+		case 0, 1:
+			return identifier(g.name())
+		case 2:
+			return g.number()
+		case 3:
+			value := g.rand.Intn(2) == 0
+			literal := strconv.FormatBool(value)
+			tt := lexer.False
+			if value {
+				tt = lexer.True
+			}
+			return &ast.Boolean{Token: lexer.Token{Type: tt, Literal: literal}, Value: value}
+		case 4:
+			if !g.config.Strings {
+				continue
+			}
+			text := g.text()
+			return &ast.StringLiteral{Token: lexer.Token{Type: lexer.String, Literal: text}, Value: text}
+		}
+	}
+}
+
+func (g *Generator) number() ast.Expression {
+	value := int64(g.rand.Intn(100))
+	if !g.config.Numbers {
+		return integer(strconv.FormatInt(value, 10), value)
+	}
+
+	switch g.rand.Intn(6) {
+	case 0:
+		return integer(fmt.Sprintf("0x%X", value), value)
+	case 1:
+		return integer(fmt.Sprintf("0b%b", value), value)
+	case 2:
+		value = int64(g.rand.Intn(100000))
+		s := strconv.FormatInt(value, 10)
+		if len(s) > 3 {
+			s = s[:len(s)-3] + "_" + s[len(s)-3:]
+		}
+		return integer(s, value)
+	case 3:
+		literal := "123456789012345678901234567890"
+		value, _ := new(big.Int).SetString(literal, 10)
+		return &ast.BigIntegerLiteral{
+			Token: lexer.Token{Type: lexer.Int, Literal: literal},
+			Value: value,
+		}
+	case 4:
+		f := float64(g.rand.Intn(10000)) / 100
+		literal := strconv.FormatFloat(f, 'f', -1, 64)
+		if _, err := strconv.ParseInt(literal, 10, 64); err == nil {
+			literal += ".0"
+		}
+		return &ast.FloatLiteral{Token: lexer.Token{Type: lexer.Float, Literal: literal}, Value: f}
+	default:
+		return integer(strconv.FormatInt(value, 10), value)
+	}
+}
+
+// texts are the pieces strings are made of, including every character
+// that has to be escaped.
+var texts = []string{"", "a", "hello", " ", "\"", "\\", "\n", "\t", "$", "{", "}", "${", "ü", "名"}
+
+func (g *Generator) text() string {
+	s := ""
+	for n := g.rand.Intn(4); n > 0; n-- {
+		s += g.choose(texts)
+	}
+	return s
+}
+
+// name returns a name in scope, or a new one if there is none.
+func (g *Generator) name() string {
+	var names []string
+	for _, scope := range g.scopes {
+		names = append(names, scope...)
+	}
+	if len(names) == 0 {
+		g.names++
+		return fmt.Sprintf("y%d", g.names)
+	}
+	return g.choose(names)
+}
+
+// newName binds a new name in the innermost scope.
+func (g *Generator) newName(prefix string) string {
+	g.names++
+	name := fmt.Sprintf("%s%d", prefix, g.names)
+	last := len(g.scopes) - 1
+	g.scopes[last] = append(g.scopes[last], name)
+	return name
+}
+
+func (g *Generator) choose(options []string) string {
+	return options[g.rand.Intn(len(options))]
+}
+
+func identifier(name string) *ast.Identifier {
+	return &ast.Identifier{Token: lexer.Token{Type: lexer.Ident, Literal: name}, Value: name}
+}
+
+func integer(literal string, value int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: lexer.Token{Type: lexer.Int, Literal: literal}, Value: value}
+}
+
+func operatorToken(op string) lexer.Token {
+	return lexer.Token{Type: tokenTypes[op], Literal: op}
+}
+
+// firstToken returns the token an expression statement starts with, which
+// the parser records on the statement, ignoring any parentheses the printer
+// adds around the leftmost operand.
+func firstToken(expr ast.Expression) lexer.Token {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return firstToken(expr.Left)
+	case *ast.AssignExpression:
+		return firstToken(expr.Target)
+	case *ast.CallExpression:
+		return firstToken(expr.Function)
+	case *ast.IndexExpression:
+		return firstToken(expr.Left)
+	case *ast.PrefixExpression:
+		return expr.Token
+	case *ast.Identifier:
+		return expr.Token
+	case *ast.IntegerLiteral:
+		return expr.Token
+	case *ast.BigIntegerLiteral:
+		return expr.Token
+	case *ast.FloatLiteral:
+		return expr.Token
+	case *ast.Boolean:
+		return expr.Token
+	case *ast.StringLiteral:
+		return expr.Token
+	case *ast.TemplateLiteral:
+		return expr.Token
+	case *ast.IfExpression:
+		return expr.Token
+	case *ast.FunctionLiteral:
+		return expr.Token
+	case *ast.ArrayLiteral:
+		return expr.Token
+	default:
+		return lexer.Token{}
+	}
+}
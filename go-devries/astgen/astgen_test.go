@@ -0,0 +1,60 @@
+package astgen_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"monkey/ast"
+	"monkey/astgen"
+	"monkey/lexer"
+	"monkey/printer"
+)
+
+func TestSameSeedSameProgram(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		a := astgen.New(rand.New(rand.NewSource(seed)), astgen.Full()).Program()
+		b := astgen.New(rand.New(rand.NewSource(seed)), astgen.Full()).Program()
+
+		if ast.SExpr(a) != ast.SExpr(b) {
+			t.Fatalf("seed %d produced two programs:\n%s\n%s", seed, ast.SExpr(a), ast.SExpr(b))
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	config := astgen.Full()
+	config.MaxStatements = 3
+	g := astgen.New(rand.New(rand.NewSource(1)), config)
+
+	for i := 0; i < 200; i++ {
+		program := g.Program()
+		if n := len(program.Statements); n > config.MaxStatements {
+			t.Fatalf("program has %d statements, more than %d", n, config.MaxStatements)
+		}
+	}
+}
+
+func TestBasicStaysInBasicLanguage(t *testing.T) {
+	basic := map[lexer.TokenType]bool{}
+	for _, tt := range []lexer.TokenType{
+		lexer.Eof, lexer.Ident, lexer.Int, lexer.Assign, lexer.Plus, lexer.Minus,
+		lexer.Bang, lexer.Asterisk, lexer.ForwardSlash, lexer.LessThan,
+		lexer.GreaterThan, lexer.Equal, lexer.NotEqual, lexer.Comma,
+		lexer.Semicolon, lexer.LParen, lexer.RParen, lexer.LSquirly,
+		lexer.RSquirly, lexer.Function, lexer.Let, lexer.True, lexer.False,
+		lexer.If, lexer.Else, lexer.Return,
+	} {
+		basic[tt] = true
+	}
+
+	g := astgen.New(rand.New(rand.NewSource(1)), astgen.Basic())
+	for i := 0; i < 200; i++ {
+		source := printer.String(g.Program())
+		l := lexer.NewLexer(source)
+		for tok, ok := l.Next(); ok; tok, ok = l.Next() {
+			if !basic[tok.Type] {
+				t.Fatalf("%v outside the basic language in:\n%s", tok, source)
+			}
+		}
+	}
+}
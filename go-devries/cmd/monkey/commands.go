@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/printer"
+	"monkey/repl"
+	"monkey/resolver"
+)
+
+func runTokens(c *invocation, args []string) int {
+	fs := c.flags("tokens")
+	if fs.Parse(args) != nil {
+		return exitUsage
+	}
+	_, source, status := c.input(fs)
+	if status != exitOK {
+		return status
+	}
+
+	status = exitOK
+	l := lexer.NewLexer(source)
+	for tok, ok := l.Next(); ok; tok, ok = l.Next() {
+		if tok.Type == lexer.Eof {
+			fmt.Fprintf(c.stdout, "%s\n", tok.Type)
+		} else {
+			fmt.Fprintf(c.stdout, "%s %q\n", tok.Type, tok.Literal)
+		}
+		if tok.Type == lexer.Illegal {
+			status = exitSyntax
+		}
+	}
+	return status
+}
+
+func runParse(c *invocation, args []string) int {
+	fs := c.flags("parse")
+	c.diagnosticFlags(fs)
+	if fs.Parse(args) != nil {
+		return exitUsage
+	}
+	_, program, status := c.parse(fs, false)
+	if status != exitOK {
+		return status
+	}
+
+	if len(program.Statements) > 0 {
+		fmt.Fprintln(c.stdout, ast.SExpr(program))
+	}
+	return exitOK
+}
+
+func runFmt(c *invocation, args []string) int {
+	fs := c.flags("fmt")
+	c.diagnosticFlags(fs)
+	write := fs.Bool("w", false, "write the result to the file instead of standard output")
+	if fs.Parse(args) != nil {
+		return exitUsage
+	}
+	if *write && fs.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "monkey fmt: -w needs a file")
+		return exitUsage
+	}
+	_, program, status := c.parse(fs, false)
+	if status != exitOK {
+		return status
+	}
+
+	if *write {
+		if err := os.WriteFile(fs.Arg(0), []byte(printer.String(program)), 0o644); err != nil {
+			fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	if err := printer.Fprint(c.stdout, program); err != nil {
+		fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// eval runs a program for the run command. Tests replace it.
+var eval = evaluator.Eval
+
+func runRun(c *invocation, args []string) (status int) {
+	fs := c.flags("run")
+	c.diagnosticFlags(fs)
+	if fs.Parse(args) != nil {
+		return exitUsage
+	}
+	file, program, status := c.parse(fs, true)
+	if status != exitOK {
+		return status
+	}
+
+	// A panic in the evaluator is a runtime failure too, not a usage error
+	// as the status it would otherwise exit with suggests.
+	defer func() {
+		if r := recover(); r != nil {
+			c.report(file, diag.Diagnostic{
+				Severity: diag.Error,
+				Span:     diag.NoSpan,
+				Message:  fmt.Sprintf("runtime error: internal error: %v", r),
+			})
+			status = exitRuntime
+		}
+	}()
+
+	env := object.NewEnvironment()
+	env.SetOutput(c.stdout)
+	result := eval(program, env)
+	if err, ok := result.(*object.Error); ok {
+		c.report(file, diag.Diagnostic{
+			Severity:   diag.Error,
+			Span:       diag.NoSpan,
+			Message:    "runtime error: " + err.Message,
+			Suggestion: err.Suggestion,
+		})
+		return exitRuntime
+	}
+	return exitOK
+}
+
+func runCheck(c *invocation, args []string) int {
+	fs := c.flags("check")
+	c.diagnosticFlags(fs)
+	if fs.Parse(args) != nil {
+		return exitUsage
+	}
+	_, _, status := c.parse(fs, true)
+	return status
+}
+
+func runRepl(c *invocation, args []string) int {
+	fs := c.flags("repl")
+	if fs.Parse(args) != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	fmt.Fprintln(c.stdout, "Don't look now, there's a monkey on your back!")
+	repl.Start(c.stdin, c.stdout)
+	return exitOK
+}
+
+// parse reads and parses the program named by the arguments of fs, and
+// reports its syntax errors and warnings. Only errors make it fail. If
+// resolve is set, the names in the program that refer to nothing are errors
+// too, reported instead of any warning at the same place.
+func (c *invocation) parse(fs *flag.FlagSet, resolve bool) (file *diag.File, program *ast.Program, status int) {
+	name, source, status := c.input(fs)
+	if status != exitOK {
+		return nil, nil, status
+	}
+
+	file = diag.NewFile(name, source)
+	p := parser.New(lexer.NewLexer(source))
+	program = p.ParseProgram()
+	if len(p.Errors) > 0 {
+		c.report(file, p.Diagnostics...)
+		return file, nil, exitSyntax
+	}
+
+	diagnostics := p.Diagnostics
+	if resolve {
+		result := resolver.Resolve(program, resolver.Config{})
+		diagnostics = diag.Merge(diagnostics, result.Diagnostics)
+		if len(result.Diagnostics) > 0 {
+			program, status = nil, exitSyntax
+		}
+	}
+	c.report(file, diagnostics...)
+	return file, program, status
+}
@@ -0,0 +1,200 @@
+// Command monkey lexes, parses, formats and runs Monkey programs.
+//
+// Usage:
+//
+//	monkey <command> [arguments] [file]
+//
+// The commands are:
+//
+//	tokens  print the tokens of a program
+//	parse   print the syntax tree of a program as S-expressions
+//	fmt     print a program in canonical form
+//	run     run a program
+//	check   report the errors in a program found without running it
+//	repl    start an interactive session
+//
+// Every command but repl reads the program from the named file, or from
+// standard input if there is none. Errors in the program are shown with the
+// line they are on; the -json flag reports them as JSON for tools instead,
+// and -color decides whether they are coloured. The exit status is 0 on success, 1 if
+// the input cannot be read or written, 2 for a usage error, 3 if the
+// program has a syntax error or uses a name it never declares, and 4 if
+// running it fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"monkey/diag"
+)
+
+const (
+	exitOK = iota
+	exitFailure
+	exitUsage
+	exitSyntax
+	exitRuntime
+)
+
+// command is one subcommand. run gets the arguments after the command's
+// name and returns the exit status.
+type command struct {
+	name  string
+	args  string
+	short string
+	run   func(c *invocation, args []string) int
+}
+
+var commands []*command
+
+func init() {
+	// Assigned here rather than in the declaration, since help refers back
+	// to commands.
+	commands = []*command{
+		{"tokens", "[file]", "print the tokens of a program", runTokens},
+		{"parse", "[file]", "print the syntax tree of a program as S-expressions", runParse},
+		{"fmt", "[-w] [file]", "print a program in canonical form", runFmt},
+		{"run", "[file]", "run a program", runRun},
+		{"check", "[file]", "report the errors in a program found without running it", runCheck},
+		{"repl", "", "start an interactive session", runRepl},
+		{"help", "[command]", "describe a command", runHelp},
+	}
+}
+
+// invocation holds the standard streams of one run of the command, so that
+// tests can run it in-process.
+type invocation struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// json and color are the values of the -json and -color flags of the
+	// commands that report errors in the program.
+	json  bool
+	color string
+}
+
+func main() {
+	c := &invocation{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(c.main(os.Args[1:]))
+}
+
+func (c *invocation) main(args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return exitUsage
+	}
+
+	cmd := lookup(args[0])
+	if cmd == nil {
+		fmt.Fprintf(c.stderr, "monkey: unknown command %q\n", args[0])
+		c.usage()
+		return exitUsage
+	}
+	return cmd.run(c, args[1:])
+}
+
+func lookup(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func (c *invocation) usage() {
+	fmt.Fprintf(c.stderr, "usage: monkey <command> [arguments] [file]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-7s %s\n", cmd.name, cmd.short)
+	}
+}
+
+// flags returns the flag set for a command, which reports errors and usage
+// to the invocation's standard error.
+func (c *invocation) flags(name string) *flag.FlagSet {
+	cmd := lookup(name)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: monkey %s %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// diagnosticFlags adds the flags that choose how errors in the program
+// are reported to fs.
+func (c *invocation) diagnosticFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.json, "json", false, "report errors as JSON, one object per line")
+	fs.StringVar(&c.color, "color", "auto", "colour errors: always, never or auto, for a terminal")
+}
+
+// report writes diagnostics about the program in file to standard error.
+func (c *invocation) report(file *diag.File, diagnostics ...diag.Diagnostic) {
+	r := diag.Renderer{}
+	if c.json {
+		r.Format = diag.JSON
+	}
+	switch c.color {
+	case "always":
+		r.Color = true
+	case "auto":
+		r.Color = isTerminal(c.stderr) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	}
+	if err := r.Render(c.stderr, file, diagnostics...); err != nil {
+		fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// input reads the program named by the remaining arguments of fs, or
+// standard input if there are none. It returns the name to report errors
+// under, or an exit status other than exitOK on failure.
+func (c *invocation) input(fs *flag.FlagSet) (name, source string, status int) {
+	switch fs.NArg() {
+	case 0:
+		data, err := io.ReadAll(c.stdin)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+			return "", "", exitFailure
+		}
+		return "<stdin>", string(data), exitOK
+	case 1:
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+			return "", "", exitFailure
+		}
+		return fs.Arg(0), string(data), exitOK
+	default:
+		fs.Usage()
+		return "", "", exitUsage
+	}
+}
+
+func runHelp(c *invocation, args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return exitOK
+	}
+
+	cmd := lookup(args[0])
+	if cmd == nil || len(args) > 1 {
+		c.usage()
+		return exitUsage
+	}
+	fmt.Fprintf(c.stderr, "usage: monkey %s %s\n\n%s.\n", cmd.name, cmd.args, cmd.short)
+	return exitOK
+}
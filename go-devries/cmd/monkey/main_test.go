@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/object"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// monkey runs the command in-process.
+func monkey(stdin string, args ...string) (stdout, stderr string, status int) {
+	var out, errOut bytes.Buffer
+	c := &invocation{stdin: strings.NewReader(stdin), stdout: &out, stderr: &errOut}
+	status = c.main(args)
+	return out.String(), errOut.String(), status
+}
+
+// transcript describes a run the way a shell session would show it.
+func transcript(args []string, stdout, stderr string, status int) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "$ monkey %s\n", strings.Join(args, " "))
+	out.WriteString(stdout)
+	if stderr != "" {
+		out.WriteString("stderr:\n" + stderr)
+	}
+	if status != exitOK {
+		fmt.Fprintf(&out, "exit status %d\n", status)
+	}
+	return out.String()
+}
+
+// TestGolden runs each command on every program in testdata and compares
+// the transcripts with testdata/<program>.golden.
+func TestGolden(t *testing.T) {
+	programs, err := filepath.Glob(filepath.Join("testdata", "*.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, program := range programs {
+		var got strings.Builder
+		for _, cmd := range []string{"tokens", "parse", "fmt", "check", "run"} {
+			args := []string{cmd, filepath.ToSlash(program)}
+			stdout, stderr, status := monkey("", args...)
+			got.WriteString(transcript(args, stdout, stderr, status) + "\n")
+		}
+
+		golden := strings.TrimSuffix(program, ".mk") + ".golden"
+		if *update {
+			if err := os.WriteFile(golden, []byte(got.String()), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != string(want) {
+			t.Errorf("%s: transcript differs from %s:\n%s", program, golden, got.String())
+		}
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		stdin    string
+		args     []string
+		expected int
+	}{
+		{"puts(1)", []string{"run"}, exitOK},
+		{"puts(", []string{"run"}, exitSyntax},
+		{"1 / 0", []string{"run"}, exitRuntime},
+		{"1 / 0", []string{"check"}, exitOK},
+		{"let x = if (true) {}; puts(x + 1);", []string{"run"}, exitRuntime},
+		{"puts(fn(){}());", []string{"run"}, exitOK},
+		{"", []string{"run", "testdata/missing.mk"}, exitFailure},
+		{"", []string{"run", "a.mk", "b.mk"}, exitUsage},
+		{"", []string{"fmt", "-x"}, exitUsage},
+		{"", []string{"fmt", "-w"}, exitUsage},
+		{"", []string{"frobnicate"}, exitUsage},
+		{"", nil, exitUsage},
+		{"", []string{"help", "fmt"}, exitOK},
+	}
+
+	for _, tt := range tests {
+		if _, _, status := monkey(tt.stdin, tt.args...); status != tt.expected {
+			t.Errorf("monkey %v: expected exit status %d, got %d", tt.args, tt.expected, status)
+		}
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	defer func(saved func(ast.Node, *object.Environment) object.Object) { eval = saved }(eval)
+	eval = func(ast.Node, *object.Environment) object.Object {
+		panic("broken")
+	}
+
+	_, stderr, status := monkey("1", "run")
+	if status != exitRuntime {
+		t.Errorf("expected exit status %d, got %d", exitRuntime, status)
+	}
+	if want := "<stdin>: runtime error: internal error: broken\n"; stderr != want {
+		t.Errorf("expected stderr=%q, got %q", want, stderr)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	stdout, stderr, status := monkey("puts(1 + 2)", "run")
+	if stdout != "3\n" || stderr != "" || status != exitOK {
+		t.Errorf("run from stdin: stdout=%q stderr=%q status=%d", stdout, stderr, status)
+	}
+
+	_, stderr, _ = monkey("let 1", "check")
+	if want := "<stdin>:1:5: expected next token to be Ident, got Int instead\n1 | let 1\n  |     ^\n"; stderr != want {
+		t.Errorf("check from stdin: expected stderr=%q, got %q", want, stderr)
+	}
+}
+
+func TestDiagnosticFlags(t *testing.T) {
+	_, stderr, status := monkey("let x = 1 +\n", "check", "-json")
+	want := `{"file":"<stdin>","severity":"error","message":"no prefix parse function for Eof found",` +
+		`"line":1,"column":12,"endLine":1,"endColumn":12,"offset":11,"end":11,` +
+		`"notes":["the input ends before the expression does"]}` + "\n"
+	if stderr != want || status != exitSyntax {
+		t.Errorf("check -json: expected stderr=%q, got %q (status %d)", want, stderr, status)
+	}
+
+	_, stderr, _ = monkey("1 / 0", "run", "-json")
+	if want := `{"file":"<stdin>","severity":"error","message":"runtime error: division by zero"}` + "\n"; stderr != want {
+		t.Errorf("run -json: expected stderr=%q, got %q", want, stderr)
+	}
+
+	_, stderr, status = monkey("let value = 1;\nvalu", "run", "-json")
+	want = `{"file":"<stdin>","severity":"error","message":"identifier not found: valu",` +
+		`"line":2,"column":1,"endLine":2,"endColumn":5,"offset":15,"end":19,"suggestion":"value"}` + "\n"
+	if stderr != want || status != exitSyntax {
+		t.Errorf("run -json: expected stderr=%q before running, got %q (status %d)", want, stderr, status)
+	}
+
+	_, stderr, _ = monkey("let 1", "check", "-color", "always")
+	if !strings.Contains(stderr, "\x1b[") {
+		t.Errorf("check -color always: expected ANSI escapes, got %q", stderr)
+	}
+	_, stderr, _ = monkey("let 1", "check")
+	if strings.Contains(stderr, "\x1b[") {
+		t.Errorf("check: expected no colour when not writing to a terminal, got %q", stderr)
+	}
+}
+
+func TestFmtWrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prog.mk")
+	if err := os.WriteFile(file, []byte("let x=1+2*3;x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if stdout, stderr, status := monkey("", "fmt", "-w", file); stdout != "" || stderr != "" || status != exitOK {
+		t.Fatalf("fmt -w: stdout=%q stderr=%q status=%d", stdout, stderr, status)
+	}
+
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "let x = 1 + 2 * 3;\nx;\n"; string(got) != want {
+		t.Errorf("fmt -w wrote %q, want %q", got, want)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	stdout, _, status := monkey("let x = 1 + 2\nx * 2\n", "repl")
+	if status != exitOK {
+		t.Fatalf("repl exited with status %d", status)
+	}
+	if !strings.Contains(stdout, ">> 6\n") {
+		t.Errorf("repl did not keep x between lines:\n%s", stdout)
+	}
+}
@@ -0,0 +1,5 @@
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n-1) + fib(n-2)
+};
+puts(fib(15), [1, 2, 3 * 4][2], 2 ** 100);
@@ -0,0 +1,3 @@
+let name = "monkey";
+let greet = fn(who) { "hello, ${who}!" };
+puts(greet(name));
@@ -0,0 +1,4 @@
+let x = 5;
+puts(x);
+x + true;
+puts("unreachable");
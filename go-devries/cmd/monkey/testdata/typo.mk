@@ -0,0 +1,6 @@
+let total = 0;
+let add = fn(x) {
+	total += x;
+};
+add(1);
+retrun total;
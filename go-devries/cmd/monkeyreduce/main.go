@@ -0,0 +1,93 @@
+// Command monkeyreduce shrinks a Monkey program while a test command keeps
+// succeeding on it:
+//
+//	monkeyreduce crash.mk ./still-crashes.sh
+//
+// Each candidate is written to a temporary file whose path is appended to
+// the command's arguments. The candidate is kept if the command exits with
+// status 0. The smallest program found is written to standard output.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"monkey/reduce"
+)
+
+func main() {
+	output := flag.String("o", "", "write the reduced program to `file` instead of standard output")
+	verbose := flag.Bool("v", false, "report the size of each smaller program found")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-o file] [-v] input command [args...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Args()[1:], *output, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, "monkeyreduce:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input string, command []string, output string, verbose bool) error {
+	source, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "monkeyreduce")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	candidate := filepath.Join(dir, filepath.Base(input))
+
+	smallest := len(source)
+	var testErr error
+	interesting := func(source string) bool {
+		if testErr != nil {
+			return false
+		}
+		if testErr = os.WriteFile(candidate, []byte(source), 0o644); testErr != nil {
+			return false
+		}
+
+		cmd := exec.Command(command[0], append(command[1:], candidate)...)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			testErr = err
+			return false
+		}
+
+		if err == nil && verbose && len(source) < smallest {
+			smallest = len(source)
+			fmt.Fprintf(os.Stderr, "%d bytes\n", smallest)
+		}
+		return err == nil
+	}
+
+	reduced, err := reduce.Reduce(string(source), interesting)
+	if testErr != nil {
+		return testErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	if output == "" {
+		_, err = fmt.Println(reduced)
+		return err
+	}
+	return os.WriteFile(output, []byte(reduced+"\n"), 0o644)
+}
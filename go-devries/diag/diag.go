@@ -0,0 +1,169 @@
+// Package diag describes problems found in Monkey source and renders them
+// for people or for tools. As text, a diagnostic shows the line it refers
+// to with the offending span underlined:
+//
+//	fib.mk:3:12: no prefix parse function for Eof found
+//	3 | let x = 1 +
+//	  |            ^
+//	  = note: the input ends before the expression does
+//
+// optionally coloured with ANSI escape sequences. As JSON, each diagnostic
+// is an object on a line of its own.
+package diag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity tells how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Span is a range of byte offsets in the source, from Start up to End.
+//
+// The zero Span refers to no place, so that a node built other than by the
+// parser, which has no span set, is not taken to be at the start of the
+// source. Nothing is reported at an empty span there anyway: the source is
+// either empty, and has no problems, or has a token at its start.
+type Span struct {
+	Start, End int
+}
+
+// NoSpan is the span of a diagnostic that refers to no particular place.
+var NoSpan = Span{}
+
+// Valid reports whether s refers to a place in the source.
+func (s Span) Valid() bool {
+	return s != NoSpan && s.Start >= 0 && s.End >= s.Start
+}
+
+// Diagnostic is one problem found in the source.
+type Diagnostic struct {
+	Severity Severity
+	Span     Span
+	Message  string
+
+	// Notes are shown after the source, one per line.
+	Notes []string
+
+	// Suggestion, if set, is what was probably meant instead of the text
+	// at Span, such as the keyword a misspelled name is closest to.
+	Suggestion string
+}
+
+func (d Diagnostic) Error() string {
+	return d.Message
+}
+
+// Merge returns ds followed by more, without the warnings in ds at the
+// span of an error in more. Such a warning, as a parser gives for a name
+// that looks like a misspelled keyword, would only say again what the
+// error does.
+func Merge(ds, more []Diagnostic) []Diagnostic {
+	errs := map[Span]bool{}
+	for _, d := range more {
+		if d.Severity == Error && d.Span.Valid() {
+			errs[d.Span] = true
+		}
+	}
+
+	var merged []Diagnostic
+	for _, d := range ds {
+		if d.Severity != Warning || !errs[d.Span] {
+			merged = append(merged, d)
+		}
+	}
+	return append(merged, more...)
+}
+
+// Position is a place in the source. Line and Column count from 1, and
+// columns count characters rather than bytes.
+type Position struct {
+	Line, Column int
+}
+
+// File is a named source for diagnostics to refer to.
+type File struct {
+	Name   string
+	Source string
+
+	// lines holds the offset at which each line starts.
+	lines []int
+}
+
+// NewFile returns a file holding source, reported under name. The name of
+// a file that is not on disk, such as a line of REPL input, may be empty.
+func NewFile(name, source string) *File {
+	f := &File{Name: name, Source: source, lines: []int{0}}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	return f
+}
+
+// Position returns the position of the byte at offset, which is clamped to
+// the source.
+func (f *File) Position(offset int) Position {
+	offset = f.clamp(offset)
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	column := utf8.RuneCountInString(f.Source[f.lines[line]:offset]) + 1
+	return Position{line + 1, column}
+}
+
+// Line returns the text of line n, counting from 1, without its newline.
+func (f *File) Line(n int) string {
+	if n < 1 || n > len(f.lines) {
+		return ""
+	}
+	start, end := f.lines[n-1], len(f.Source)
+	if n < len(f.lines) {
+		end = f.lines[n] - 1
+	}
+	return strings.TrimSuffix(f.Source[start:end], "\r")
+}
+
+func (f *File) clamp(offset int) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset > len(f.Source) {
+		return len(f.Source)
+	}
+	return offset
+}
+
+// locate returns the span that a diagnostic should point at for s. An
+// empty span at the end of the input, such as that of an Eof token, is
+// moved back to just after the last character that is not white space, so
+// that it points at the line that was left unfinished.
+func (f *File) locate(s Span) Span {
+	s = Span{f.clamp(s.Start), f.clamp(s.End)}
+	if s.Start == s.End && s.Start == len(f.Source) {
+		end := len(strings.TrimRight(f.Source, " \t\r\n"))
+		s = Span{end, end}
+	}
+	return s
+}
@@ -0,0 +1,173 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPosition(t *testing.T) {
+	f := NewFile("a.mk", "let é = 1;\nx\n\ny")
+	tests := []struct {
+		offset   int
+		expected Position
+	}{
+		{0, Position{1, 1}},
+		{4, Position{1, 5}},
+		{6, Position{1, 6}},
+		{11, Position{1, 11}},
+		{12, Position{2, 1}},
+		{14, Position{3, 1}},
+		{15, Position{4, 1}},
+		{99, Position{4, 2}},
+		{-1, Position{1, 1}},
+	}
+
+	for _, tt := range tests {
+		if got := f.Position(tt.offset); got != tt.expected {
+			t.Errorf("offset %d: expected %v, got %v", tt.offset, tt.expected, got)
+		}
+	}
+
+	if got := f.Line(2); got != "x" {
+		t.Errorf("expected line 2 to be %q, got %q", "x", got)
+	}
+	if got := f.Line(5); got != "" {
+		t.Errorf("expected no line 5, got %q", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			"a.mk", "let x = 1 @ 2;",
+			Diagnostic{Error, Span{10, 11}, "illegal", []string{"first", "second"}, ""},
+			"a.mk:1:11: illegal\n1 | let x = 1 @ 2;\n  |           ^\n  = note: first\n  = note: second\n",
+		},
+		{
+			"a.mk", "x\n\tif (y) {\n",
+			Diagnostic{Warning, Span{3, 5}, "odd", nil, ""},
+			"a.mk:2:2: warning: odd\n2 | \tif (y) {\n  | \t^^\n",
+		},
+		{
+			"", "let x = (1 +\n\n",
+			Diagnostic{Error, Span{14, 14}, "unfinished", nil, ""},
+			"unfinished\n1 | let x = (1 +\n  |             ^\n",
+		},
+		{
+			"long.mk", strings.Repeat("\n", 11) + "if (x) {\n  y\n}",
+			Diagnostic{Error, Span{15, 27}, "spans lines", nil, ""},
+			"long.mk:12:5: spans lines\n12 | if (x) {\n   |     ^^^^\n",
+		},
+		{
+			"a.mk", "whatever",
+			Diagnostic{Note, NoSpan, "no place", []string{"at all"}, ""},
+			"a.mk: note: no place\n = note: at all\n",
+		},
+		{
+			"a.mk", "whatever",
+			Diagnostic{Error, Span{}, "built by hand", nil, ""},
+			"a.mk: built by hand\n",
+		},
+		{
+			"a.mk", "retrun x;",
+			Diagnostic{Warning, Span{0, 6}, "retrun is not a keyword", nil, "return"},
+			"a.mk:1:1: warning: retrun is not a keyword\n1 | retrun x;\n  | ^^^^^^\n  = help: did you mean return?\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got := String(NewFile(tt.name, tt.source), tt.diagnostic)
+		if got != tt.expected {
+			t.Errorf("%q:\nexpected:\n%s\ngot:\n%s", tt.diagnostic.Message, tt.expected, got)
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	var out strings.Builder
+	d := Diagnostic{Error, Span{0, 1}, "bad", []string{"why"}, ""}
+	if err := (Renderer{Color: true}).Render(&out, NewFile("a.mk", "@"), d); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bold + "a.mk:1:1:" + reset + " " + bold + "bad" + reset + "\n" +
+		blue + "1" + reset + " " + blue + "|" + reset + " @\n" +
+		"  " + blue + "|" + reset + " " + red + "^" + reset + "\n" +
+		"  " + blue + "=" + reset + " " + cyan + "note:" + reset + " why\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var out strings.Builder
+	r := Renderer{Format: JSON}
+	err := r.Render(&out, NewFile("<a>", "x\ny + z"),
+		Diagnostic{Error, Span{2, 7}, "bad", []string{"why"}, ""},
+		Diagnostic{Warning, NoSpan, "vague", nil, "clear"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"file":"<a>","severity":"error","message":"bad","line":2,"column":1,"endLine":2,"endColumn":6,"offset":2,"end":7,"notes":["why"]}` + "\n" +
+		`{"file":"<a>","severity":"warning","message":"vague","suggestion":"clear"}` + "\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	warnings := []Diagnostic{
+		{Warning, Span{0, 6}, "retrun is not a keyword", nil, "return"},
+		{Warning, Span{9, 12}, "iff is not a keyword", nil, "if"},
+		{Error, Span{20, 21}, "syntax", nil, ""},
+	}
+	errs := []Diagnostic{
+		{Error, Span{0, 6}, "identifier not found: retrun", nil, "return"},
+		{Error, Span{20, 21}, "identifier not found: x", nil, ""},
+		{Warning, Span{9, 12}, "unrelated", nil, ""},
+	}
+
+	var got []string
+	for _, d := range Merge(warnings, errs) {
+		got = append(got, d.Message)
+	}
+	expected := "iff is not a keyword|syntax|identifier not found: retrun|identifier not found: x|unrelated"
+	if strings.Join(got, "|") != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"fn", "let", "true", "false", "if", "else", "return", "length", "function"}
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{"retrun", "return"},
+		{"retunr", "return"},
+		{"fucntion", "function"},
+		{"lte", "let"},
+		{"iff", "if"},
+		{"flase", "false"},
+		{"lenght", "length"},
+		{"x", ""},
+		{"lt", "let"},
+		{"return", ""},
+		{"totally", ""},
+		{"élse", "else"},
+	}
+
+	for _, tt := range tests {
+		got, ok := Closest(tt.word, candidates)
+		if ok != (tt.expected != "") || ok && got != tt.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", tt.word, tt.expected, got, ok)
+		}
+	}
+}
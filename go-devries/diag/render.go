@@ -0,0 +1,188 @@
+package diag
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is the form diagnostics are rendered in.
+type Format int
+
+const (
+	// Text shows each diagnostic with the source line it refers to.
+	Text Format = iota
+	// JSON writes each diagnostic as a JSON object on its own line.
+	JSON
+)
+
+// Renderer writes diagnostics. The zero Renderer writes plain text.
+type Renderer struct {
+	Format Format
+
+	// Color adds ANSI colours to text.
+	Color bool
+}
+
+// ANSI escape sequences used when colouring text.
+const (
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	cyan   = "\x1b[1;36m"
+	blue   = "\x1b[1;34m"
+	reset  = "\x1b[0m"
+)
+
+// Render writes the diagnostics about f to w.
+func (r Renderer) Render(w io.Writer, f *File, diagnostics ...Diagnostic) error {
+	bw := bufio.NewWriter(w)
+	for _, d := range diagnostics {
+		var err error
+		if r.Format == JSON {
+			err = r.json(bw, f, d)
+		} else {
+			r.text(bw, f, d)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// String returns the diagnostics about f as plain text.
+func String(f *File, diagnostics ...Diagnostic) string {
+	var out strings.Builder
+	Renderer{}.Render(&out, f, diagnostics...)
+	return out.String()
+}
+
+func (r Renderer) paint(color, s string) string {
+	if !r.Color {
+		return s
+	}
+	return color + s + reset
+}
+
+func (r Renderer) text(w *bufio.Writer, f *File, d Diagnostic) {
+	// Input without a name, such as a line typed into the REPL, is shown
+	// in full below, so its position would tell nothing new.
+	if location := f.Name; location != "" {
+		if d.Span.Valid() {
+			pos := f.Position(f.locate(d.Span).Start)
+			location += fmt.Sprintf(":%d:%d", pos.Line, pos.Column)
+		}
+		w.WriteString(r.paint(bold, location+":") + " ")
+	}
+
+	// Errors are the common case and are not labelled, as in the messages
+	// of the go command.
+	switch d.Severity {
+	case Error:
+	case Warning:
+		w.WriteString(r.paint(yellow, "warning:") + " ")
+	default:
+		w.WriteString(r.paint(cyan, d.Severity.String()+":") + " ")
+	}
+	w.WriteString(r.paint(bold, d.Message) + "\n")
+
+	gutter := ""
+	if d.Span.Valid() {
+		gutter = r.snippet(w, f, d.Span)
+	}
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s %s %s\n", gutter, r.paint(blue, "="), r.paint(cyan, "note:")+" "+note)
+	}
+	if d.Suggestion != "" {
+		fmt.Fprintf(w, "%s %s %s did you mean %s?\n", gutter, r.paint(blue, "="), r.paint(cyan, "help:"), d.Suggestion)
+	}
+}
+
+// snippet writes the line that span starts on with the span underlined,
+// and returns the blank gutter to indent notes by.
+func (r Renderer) snippet(w *bufio.Writer, f *File, span Span) string {
+	span = f.locate(span)
+	start := f.Position(span.Start)
+	line := f.Line(start.Line)
+
+	number := fmt.Sprint(start.Line)
+	gutter := strings.Repeat(" ", len(number))
+	fmt.Fprintf(w, "%s %s %s\n", r.paint(blue, number), r.paint(blue, "|"), line)
+
+	// The underline starts under the first character of the span, copying
+	// tabs so that it lines up however wide they are shown, and runs to
+	// the end of the span or of the line.
+	var indent strings.Builder
+	runes := []rune(line)
+	for _, ch := range runes[:min(start.Column-1, len(runes))] {
+		if ch == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	width := 1
+	if end := f.Position(span.End); end.Line == start.Line && end.Column > start.Column {
+		width = end.Column - start.Column
+	} else if end.Line > start.Line {
+		width = max(utf8.RuneCountInString(line)-start.Column+1, 1)
+	}
+	fmt.Fprintf(w, "%s %s %s%s\n", gutter, r.paint(blue, "|"), indent.String(), r.paint(red, strings.Repeat("^", width)))
+
+	return gutter
+}
+
+// jsonDiagnostic is the JSON form of a diagnostic. Lines and columns are
+// as in Position, and offsets are in bytes.
+type jsonDiagnostic struct {
+	File       string   `json:"file,omitempty"`
+	Severity   string   `json:"severity"`
+	Message    string   `json:"message"`
+	Line       int      `json:"line,omitempty"`
+	Column     int      `json:"column,omitempty"`
+	EndLine    int      `json:"endLine,omitempty"`
+	EndColumn  int      `json:"endColumn,omitempty"`
+	Offset     *int     `json:"offset,omitempty"`
+	End        *int     `json:"end,omitempty"`
+	Notes      []string `json:"notes,omitempty"`
+	Suggestion string   `json:"suggestion,omitempty"`
+}
+
+func (r Renderer) json(w *bufio.Writer, f *File, d Diagnostic) error {
+	out := jsonDiagnostic{
+		File:       f.Name,
+		Severity:   d.Severity.String(),
+		Message:    d.Message,
+		Notes:      d.Notes,
+		Suggestion: d.Suggestion,
+	}
+	if d.Span.Valid() {
+		span := f.locate(d.Span)
+		start, end := f.Position(span.Start), f.Position(span.End)
+		out.Line, out.Column = start.Line, start.Column
+		out.EndLine, out.EndColumn = end.Line, end.Column
+		out.Offset, out.End = &span.Start, &span.End
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(out)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
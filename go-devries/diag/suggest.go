@@ -0,0 +1,54 @@
+package diag
+
+// Closest returns the candidate most like word, if one is close enough to
+// be a likely misspelling of it: within one edit for words of two to four
+// characters, two for five to seven and so on, while single characters
+// have no suggestions. An edit is inserting, deleting or changing a
+// character, or swapping two adjacent ones. Ties go to the candidate that
+// sorts first.
+func Closest(word string, candidates []string) (string, bool) {
+	runes := []rune(word)
+	limit := (len(runes) + 1) / 3
+	if limit == 0 {
+		return "", false
+	}
+
+	best, bestDistance := "", limit+1
+	for _, c := range candidates {
+		if c == word {
+			continue
+		}
+		d := distance(runes, []rune(c))
+		if d < bestDistance || d == bestDistance && c < best {
+			best, bestDistance = c, d
+		}
+	}
+	return best, bestDistance <= limit
+}
+
+// distance returns the optimal string alignment distance between a and b.
+func distance(a, b []rune) int {
+	// d[i][j] is the distance between a[:i] and b[:j].
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, min(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"monkey/object"
+)
+
+// BuiltinNames returns the names of the builtin functions, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var builtins = map[string]*object.Builtin{
+	"len": {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			default:
+				return newError("argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"puts": {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(env.Output(), arg.Inspect())
+			}
+			return Null
+		},
+	},
+}
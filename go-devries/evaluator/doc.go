@@ -0,0 +1,29 @@
+// Package evaluator is a tree-walking interpreter for Monkey programs.
+//
+// # Integers
+//
+// Integers are arbitrary precision. Values that fit in an int64 are held in
+// an *object.Integer; anything larger is held in an *object.BigInteger backed
+// by math/big. Both report the INTEGER type, and the representation is
+// chosen after every operation, so an int64 result that overflows is
+// promoted and a big result that fits again is demoted. Integer literals
+// too large for an int64 parse to *ast.BigIntegerLiteral.
+//
+// Integer division truncates towards zero and % takes the sign of the left
+// operand, as in Go. Dividing or taking the modulo by zero is the runtime
+// error "division by zero". Shifting by a negative count is an error, and >>
+// is an arithmetic shift. Bitwise operators treat negative numbers as
+// infinite two's complement.
+//
+// Promotion never wraps, but results are limited to maxIntegerBits bits (a
+// little over 300,000 decimal digits); an operation that would exceed the
+// limit fails with an "integer overflow" error.
+//
+// # Floats
+//
+// When either operand of an arithmetic or comparison operator is a float,
+// the other is converted to float64 and the result is a float, or a boolean
+// for comparisons. Raising an integer to a negative power also yields a
+// float. Division or modulo by zero is an error for floats as well, and the
+// bitwise and shift operators are only defined on integers.
+package evaluator
@@ -0,0 +1,544 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/lexer"
+	"monkey/object"
+)
+
+var (
+	Null  = &object.Null{}
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+)
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+	case *ast.ReturnStatement:
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return normalizeBig(new(big.Int).Set(node.Value))
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args, env)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	}
+
+	return nil
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement evaluates to the value of the block's last statement.
+// A block that is empty or ends in a let statement, which has no value,
+// evaluates to NULL.
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = Null
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.ReturnValueObj || rt == object.ErrorObj {
+				return result
+			}
+		}
+	}
+
+	if result == nil {
+		return Null
+	}
+	return result
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalTildePrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return normalizeBig(new(big.Int).Neg(toBig(right)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return normalizeBig(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInteger:
+		return normalizeBig(new(big.Int).Not(right.Value))
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
+}
+
+// evalLogicalExpression evaluates && and || with short-circuiting: the right
+// operand is only evaluated when the left one does not decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return False
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return True
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
+		if left, ok := left.(*object.Integer); ok {
+			if right, ok := right.(*object.Integer); ok {
+				return evalIntegerInfixExpression(operator, left, right)
+			}
+		}
+		return evalBigIntegerInfixExpression(operator, toBig(left), toBig(right))
+	case isNumeric(left) && isNumeric(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.StringObj && right.Type() == object.StringObj:
+		return evalStringInfixExpression(operator, left, right)
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression applies operator after converting both operands to
+// float64. See the package documentation for the promotion rules.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalTemplateLiteral(node *ast.TemplateLiteral, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for i, s := range node.Strings {
+		out.WriteString(s)
+		if i >= len(node.Expressions) {
+			continue
+		}
+
+		val := Eval(node.Expressions[i], env)
+		if isError(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func isNumeric(obj object.Object) bool {
+	t := obj.Type()
+	return t == object.IntegerObj || t == object.FloatObj
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	default:
+		return math.NaN()
+	}
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(ie.Consequence, object.NewEnclosedEnvironment(env))
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, object.NewEnclosedEnvironment(env))
+	} else {
+		return Null
+	}
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+
+	candidates := append(env.Names(), lexer.Keywords()...)
+	candidates = append(candidates, BuiltinNames()...)
+	return suggest(newError("identifier not found: %s", node.Value), node.Value, candidates)
+}
+
+// suggest sets the suggestion of err, about the unknown name, to the
+// closest of candidates, if any is close.
+func suggest(err *object.Error, name string, candidates []string) *object.Error {
+	err.Suggestion, _ = diag.Closest(name, candidates)
+	return err
+}
+
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// applyFunction calls fn with args. Builtins are passed caller, the
+// environment of the call.
+func applyFunction(fn object.Object, args []object.Object, caller *object.Environment) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return builtin.Fn(caller, args...)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError("not a function: %s", fn.Type())
+	}
+
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+	}
+
+	env := object.NewEnclosedEnvironment(function.Env)
+	for i, param := range function.Parameters {
+		env.Set(param.Value, args[i])
+	}
+
+	evaluated := Eval(function.Body, env)
+	if returnValue, ok := evaluated.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+
+	return evaluated
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
+		array := left.(*object.Array)
+		idx, ok := index.(*object.Integer)
+		if !ok || idx.Value < 0 || idx.Value >= int64(len(array.Elements)) {
+			return Null
+		}
+		return array.Elements[idx.Value]
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// compoundOperators maps each compound assignment operator to the infix
+// operator it applies to the old and new values.
+var compoundOperators = map[string]string{
+	"+=": "+",
+	"-=": "-",
+	"*=": "*",
+	"/=": "/",
+}
+
+// evalAssignExpression evaluates from left to right: the operands of the
+// target first, then its current value for a compound assignment, and the
+// value assigned last.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	op, compound := compoundOperators[node.Operator]
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if compound {
+			var ok bool
+			if current, ok = env.Get(target.Value); !ok {
+				return suggest(newError("assignment to undeclared identifier: %s", target.Value), target.Value, env.Names())
+			}
+		}
+
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if compound {
+			val = evalInfixExpression(op, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		if !env.Assign(target.Value, val) {
+			return suggest(newError("assignment to undeclared identifier: %s", target.Value), target.Value, env.Names())
+		}
+		return val
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		array, ok := left.(*object.Array)
+		if !ok {
+			return newError("index assignment not supported: %s", left.Type())
+		}
+		if index.Type() != object.IntegerObj {
+			return newError("index assignment not supported: %s[%s]", left.Type(), index.Type())
+		}
+		idx, ok := index.(*object.Integer)
+		if !ok || idx.Value < 0 || idx.Value >= int64(len(array.Elements)) {
+			return newError("index out of range: %s", index.Inspect())
+		}
+		current := array.Elements[idx.Value]
+
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if compound {
+			val = evalInfixExpression(op, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		array.Elements[idx.Value] = val
+		return val
+
+	default:
+		return newError("invalid assignment target: %s", node.Target)
+	}
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null:
+		return false
+	case True:
+		return true
+	case False:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ErrorObj
+	}
+	return false
+}
@@ -0,0 +1,616 @@
+package evaluator
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+)
+
+func testEval(input string) object.Object {
+	l := lexer.NewLexer(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 + 2 << 1", 6},
+		{"0x1F", 31},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"7 / 2", 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestEvalBigIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"99999999999999999999", "99999999999999999999"},
+		{"0xFFFF_FFFF_FFFF_FFFF_FFFF", "1208925819614629174706175"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"2 ** 64", "18446744073709551616"},
+		{"3 ** 50", "717897987691852588770249"},
+		{"(-2) ** 63", "-9223372036854775808"},
+		{"1 << 100", "1267650600228229401496703205376"},
+		{"99999999999999999999 * 99999999999999999999", "9999999999999999999800000000000000000001"},
+		{"99999999999999999999 % 7", "1"},
+		{"-99999999999999999999 / 7", "-14285714285714285714"},
+		{"~99999999999999999999", "-100000000000000000000"},
+		{"(1 << 100) >> 98", "4"},
+		{"(1 << 100) >> 1000", "0"},
+		{"-(1 << 100) >> 1000", "-1"},
+		{"(1 << 64 | 1) & 3", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != object.IntegerObj {
+			t.Errorf("%q: object is not an integer. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong value. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestBigIntegerDemotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"99999999999999999999 - 99999999999999999998", 1},
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"-9223372036854775808", -9223372036854775808},
+		{"(2 ** 100) / (2 ** 98)", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"2.0 ** 3", 8},
+		{"4 ** 0.5", 2},
+		{"0x10 + 0.5", 16.5},
+		{"18446744073709551616 * 0.5", 9223372036854775808},
+		{"(2 ** 64) ** -1", 1.0 / 18446744073709551616},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"!true", false},
+		{"!!5", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"1 == 1.0", true},
+		{"1 < 1.5", true},
+		{"2.5 >= 2", true},
+		{"0.1 + 0.2 != 0.3", true},
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+		{"99999999999999999999 > 9223372036854775807", true},
+		{"2 ** 64 == 18446744073709551616", true},
+		{"2 ** 64 != 2 ** 65", true},
+		{"-(2 ** 64) < 0", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (true) {}", nil},
+		{"if (true) { let x = 1 }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != Null {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { return true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"10 / 0", "division by zero"},
+		{"x = 5", "assignment to undeclared identifier: x"},
+		{"let f = fn() { y += 1 }; f()", "assignment to undeclared identifier: y"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{"let a = 1; a[0] = 2", "index assignment not supported: INTEGER"},
+		{"let x = true; x += 1", "type mismatch: BOOLEAN + INTEGER"},
+		{"10 % 0", "division by zero"},
+		{"1.5 / 0", "division by zero"},
+		{"1 % 0.0", "division by zero"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"1 << 2.0", "unknown operator: INTEGER << FLOAT"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"99999999999999999999 / 0", "division by zero"},
+		{"99999999999999999999 % 0", "division by zero"},
+		{"2 ** 2 ** 40", "integer overflow: result exceeds 1048576 bits"},
+		{"1 << (1 << 40)", "integer overflow: result exceeds 1048576 bits"},
+		{"let a = [1]; a[99999999999999999999] = 1", "index out of range: 99999999999999999999"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"a" + 1`, "type mismatch: STRING + INTEGER"},
+		{`"${missing}"`, "identifier not found: missing"},
+		{"1 << -1", "negative shift count: -1"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"true && undefined", "identifier not found: undefined"},
+		{"true & false", "unknown operator: BOOLEAN & BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestUnknownIdentifierSuggestions(t *testing.T) {
+	tests := []struct {
+		input      string
+		suggestion string
+	}{
+		{"let counter = 1; countr", "counter"},
+		{"let f = fn(value) { valeu }; f(1)", "value"},
+		{"retrun 1", "return"},
+		{"lenn([])", "len"},
+		{"let total = 0; totl += 1", "total"},
+		{"let total = 0; totl = 1", "total"},
+		{"let puts2 = 1; putss", "puts"},
+		{"banana", ""},
+		{"x", ""},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if errObj.Suggestion != tt.suggestion {
+			t.Errorf("%q: expected suggestion %q, got %q", tt.input, tt.suggestion, errObj.Suggestion)
+		}
+	}
+
+	err := &object.Error{Message: "identifier not found: retrun", Suggestion: "return"}
+	if got := err.Inspect(); got != "ERROR: identifier not found: retrun (did you mean return?)" {
+		t.Errorf("wrong Inspect output %q", got)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 2; x;", 2},
+		{"let x = 1; x = x + 1;", 2},
+		{"let x = 1; x += 4; x;", 5},
+		{"let x = 5; x -= 3; x;", 2},
+		{"let x = 5; x *= 3; x;", 15},
+		{"let x = 9; x /= 3; x;", 3},
+		{"let x = 1; let y = 1; x = y = 7; x + y;", 14},
+		{"let x = 1; if (true) { x = 2 }; x;", 2},
+		{"let x = 1; if (true) { let x = 2; x = 3 }; x;", 1},
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n;", 2},
+		{"let a = [1, 2, 3]; a[1] = 5; a[1];", 5},
+		{"let a = [1, 2, 3]; a[2] *= 4; a[2];", 12},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// TestAssignEvaluationOrder records the order in which parts of an
+// assignment run as the digits of trace.
+func TestAssignEvaluationOrder(t *testing.T) {
+	prelude := `
+let trace = 0;
+let a = [10, 20];
+let target = fn() { trace = trace * 10 + 1; a };
+let index = fn() { trace = trace * 10 + 2; 1 };
+let value = fn() { trace = trace * 10 + 3; 5 };
+`
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"target()[index()] = value(); trace", 123},
+		{"target()[index()] += value(); trace", 123},
+		{"a[index()] = value(); trace", 23},
+		{"target()[index()] += value(); a[1]", 25},
+		{"let n = 1; n += fn() { n = 10; 1 }(); n", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(prelude+tt.input), tt.expected)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	input := `"Hello" + " " + "World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ada"; "hello ${name}"`, "hello Ada"},
+		{`let users = ["Ada"]; let items = [1, 2, 3]; "hello ${users[0]}, you have ${len(items)} items"`, "hello Ada, you have 3 items"},
+		{`"${1 + 2}${true}${[1, 2]}${1.5}"`, "3true[1, 2]1.5"},
+		{`let x = 1; "outer ${"inner ${x + 1}"}"`, "outer inner 2"},
+		{`let greet = fn(n) { "hi ${n}" }; greet("Bob")`, "hi Bob"},
+		{`"\${not} interpolated"`, "${not} interpolated"},
+		{`let s = "a"; s += "${s}b"; s`, "aab"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("%s: String has wrong value. want=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+// TestPutsOutput runs programs at once in environments with outputs of
+// their own, as concurrent sessions do.
+func TestPutsOutput(t *testing.T) {
+	outputs := make([]strings.Builder, 8)
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := object.NewEnvironment()
+			env.SetOutput(&outputs[i])
+			program := parser.New(lexer.NewLexer("let f = fn(n) { puts(n) }; f(" + string(rune('0'+i)) + ")")).ParseProgram()
+			Eval(program, env)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range outputs {
+		if expected := string(rune('0'+i)) + "\n"; outputs[i].String() != expected {
+			t.Errorf("output %d: expected %q, got %q", i, expected, outputs[i].String())
+		}
+	}
+}
+
+func TestEmptyBodies(t *testing.T) {
+	tests := []struct {
+		input    string
+		output   string
+		expected string
+	}{
+		{"let x = if (true) {}; puts(x + 1);", "", "ERROR: type mismatch: NULL + INTEGER"},
+		{"puts(fn(){}());", "null\n", "null"},
+		{"let a = [fn(){}()]; puts(a);", "[null]\n", "null"},
+		{"let f = fn() { let y = 1 }; f();", "", "null"},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		env := object.NewEnvironment()
+		env.SetOutput(&out)
+		evaluated := Eval(parser.New(lexer.NewLexer(tt.input)).ParseProgram(), env)
+
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %v", tt.input, tt.expected, evaluated)
+		}
+		if out.String() != tt.output {
+			t.Errorf("%q: expected output %q, got %q", tt.input, tt.output, out.String())
+		}
+	}
+}
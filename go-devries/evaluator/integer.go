@@ -0,0 +1,238 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"monkey/object"
+)
+
+// maxIntegerBits bounds the size of a big integer result, so that something
+// like 2 ** 2 ** 40 fails with an error instead of exhausting memory.
+const maxIntegerBits = 1 << 20
+
+// evalIntegerInfixExpression applies operator to two int64 operands. Any
+// result that does not fit in an int64 is recomputed with math/big.
+func evalIntegerInfixExpression(operator string, left, right *object.Integer) object.Object {
+	leftVal := left.Value
+	rightVal := right.Value
+
+	switch operator {
+	case "+":
+		sum := leftVal + rightVal
+		if (leftVal^sum)&(rightVal^sum) < 0 {
+			break
+		}
+		return &object.Integer{Value: sum}
+	case "-":
+		diff := leftVal - rightVal
+		if (leftVal^rightVal)&(leftVal^diff) < 0 {
+			break
+		}
+		return &object.Integer{Value: diff}
+	case "*":
+		product, ok := multiplyInt64(leftVal, rightVal)
+		if !ok {
+			break
+		}
+		return &object.Integer{Value: product}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			break
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return evalFloatInfixExpression(operator, left, right)
+		}
+		power, ok := powerInt64(leftVal, rightVal)
+		if !ok {
+			break
+		}
+		return &object.Integer{Value: power}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		if leftVal == 0 {
+			return left
+		}
+		if rightVal >= 63 || (leftVal<<rightVal)>>rightVal != leftVal {
+			break
+		}
+		return &object.Integer{Value: leftVal << rightVal}
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	return evalBigIntegerInfixExpression(operator, toBig(left), toBig(right))
+}
+
+// evalBigIntegerInfixExpression applies operator with arbitrary precision.
+// Arithmetic results are demoted back to an *object.Integer when they fit.
+func evalBigIntegerInfixExpression(operator string, left, right *big.Int) object.Object {
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(left, right)
+	case "-":
+		result.Sub(left, right)
+	case "*":
+		result.Mul(left, right)
+	case "/":
+		if right.Sign() == 0 {
+			return newError("division by zero")
+		}
+		result.Quo(left, right)
+	case "%":
+		if right.Sign() == 0 {
+			return newError("division by zero")
+		}
+		result.Rem(left, right)
+	case "**":
+		if right.Sign() < 0 {
+			return evalFloatInfixExpression(operator, &object.BigInteger{Value: left}, &object.BigInteger{Value: right})
+		}
+		if left.CmpAbs(big.NewInt(1)) > 0 {
+			// A lower bound on the size of the result.
+			if !right.IsInt64() || right.Int64() > maxIntegerBits/int64(left.BitLen()-1) {
+				return integerOverflowError()
+			}
+		}
+		result.Exp(left, right, nil)
+	case "&":
+		result.And(left, right)
+	case "|":
+		result.Or(left, right)
+	case "^":
+		result.Xor(left, right)
+	case "<<":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s", right)
+		}
+		if left.Sign() == 0 {
+			return normalizeBig(result)
+		}
+		if !right.IsInt64() || right.Int64() > maxIntegerBits {
+			return integerOverflowError()
+		}
+		result.Lsh(left, uint(right.Int64()))
+	case ">>":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s", right)
+		}
+		if !right.IsUint64() || right.Uint64() > uint64(left.BitLen()) {
+			if left.Sign() < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: 0}
+		}
+		result.Rsh(left, uint(right.Uint64()))
+	case "<":
+		return nativeBoolToBooleanObject(left.Cmp(right) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(left.Cmp(right) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(left.Cmp(right) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(left.Cmp(right) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(left.Cmp(right) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(left.Cmp(right) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", object.IntegerObj, operator, object.IntegerObj)
+	}
+
+	if result.BitLen() > maxIntegerBits {
+		return integerOverflowError()
+	}
+
+	return normalizeBig(result)
+}
+
+// normalizeBig returns n as an *object.Integer if it fits in an int64 and as
+// an *object.BigInteger otherwise.
+func normalizeBig(n *big.Int) object.Object {
+	if n.IsInt64() {
+		return &object.Integer{Value: n.Int64()}
+	}
+	return &object.BigInteger{Value: n}
+}
+
+func toBig(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
+func multiplyInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
+
+func powerInt64(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for exp > 0 {
+		var ok bool
+		if exp&1 == 1 {
+			if result, ok = multiplyInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = multiplyInt64(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+func integerOverflowError() *object.Error {
+	return newError("integer overflow: result exceeds %d bits", maxIntegerBits)
+}
@@ -0,0 +1,58 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+const benchmarkProgram = `let fibonacci = fn(n) {
+	if (n <= 1) {
+		return n;
+	} else {
+		return fibonacci(n - 1) + fibonacci(n - 2);
+	}
+};
+
+let total = 0;
+let values = [1, 2, 3, 0x1F, 0b1010, 1_000_000, 3.14159, 6.02e23];
+total += len(values) * 2 ** 10 % 7;
+let greeting = "hello ${names[0]}, you have ${len(items)} items";
+let ok = total >= 10 && !(total == 12) || total << 2 != total >> 1;
+`
+
+// benchmarkCorpus is roughly a megabyte of Monkey source.
+var benchmarkCorpus = strings.Repeat(benchmarkProgram, 1<<20/len(benchmarkProgram))
+
+func BenchmarkLexer(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkCorpus)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := NewLexer(benchmarkCorpus)
+		for tok := l.NextToken(); tok.Type != Eof; tok = l.NextToken() {
+		}
+	}
+}
+
+func BenchmarkReaderLexer(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkCorpus)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := NewReaderLexer(strings.NewReader(benchmarkCorpus))
+		for tok := l.NextToken(); tok.Type != Eof; tok = l.NextToken() {
+		}
+	}
+}
+
+func BenchmarkReaderLexerLongString(b *testing.B) {
+	input := `"` + strings.Repeat("a", 1<<20) + `"`
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := NewReaderLexer(strings.NewReader(input))
+		for tok := l.NextToken(); tok.Type != Eof; tok = l.NextToken() {
+		}
+	}
+}
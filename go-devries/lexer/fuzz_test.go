@@ -0,0 +1,43 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzLexer checks that lexing any input terminates with an Eof token and
+// that the reader lexer produces the same tokens as the string lexer. The
+// seed corpus in testdata/fuzz comes from the inputs of the other tests.
+func FuzzLexer(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens := lexAll(t, NewLexer(input), len(input))
+		fromReader := lexAll(t, NewReaderLexer(strings.NewReader(input)), len(input))
+
+		if len(tokens) != len(fromReader) {
+			t.Fatalf("reader lexer produced %d tokens, lexer %d", len(fromReader), len(tokens))
+		}
+		for i := range tokens {
+			if tokens[i] != fromReader[i] {
+				t.Fatalf("token %d: reader lexer produced %v, lexer %v", i, fromReader[i], tokens[i])
+			}
+		}
+	})
+}
+
+// lexAll reads tokens until Eof. Every token but Eof consumes at least one
+// byte, so more than size+1 tokens means the lexer is stuck.
+func lexAll(t *testing.T, l *Lexer, size int) []Token {
+	var tokens []Token
+	for len(tokens) <= size {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == Eof {
+			if next := l.NextToken(); next.Type != Eof {
+				t.Fatalf("token after Eof: %v", next)
+			}
+			return tokens
+		}
+	}
+	t.Fatalf("no Eof after %d tokens, last %v", len(tokens), tokens[len(tokens)-1])
+	return nil
+}
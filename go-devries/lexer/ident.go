@@ -0,0 +1,59 @@
+package lexer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Identifiers follow the default identifier syntax of Unicode Standard Annex
+// #31, which the other Monkey lexers share: an identifier is a character with
+// the ID_Start property, or an underscore, followed by any number of
+// characters with the ID_Continue property. Whitespace is the
+// Pattern_White_Space set. go/lexer.go keeps a copy of these tables, which
+// TestCharacterClasses in go-conformance checks against this one.
+
+var idStart = []*unicode.RangeTable{
+	unicode.L,
+	unicode.Nl,
+	unicode.Other_ID_Start,
+}
+
+var idContinue = []*unicode.RangeTable{
+	unicode.L,
+	unicode.Nl,
+	unicode.Other_ID_Start,
+	unicode.Mn,
+	unicode.Mc,
+	unicode.Nd,
+	unicode.Pc,
+	unicode.Other_ID_Continue,
+}
+
+// IsIdentStart reports whether r can start an identifier.
+func IsIdentStart(r rune) bool {
+	if r < utf8.RuneSelf {
+		return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || r == '_'
+	}
+	return unicode.IsOneOf(idStart, r) && !isPattern(r)
+}
+
+// IsIdentContinue reports whether r can continue an identifier.
+func IsIdentContinue(r rune) bool {
+	if r < utf8.RuneSelf {
+		return IsIdentStart(r) || isDigit(r)
+	}
+	return unicode.IsOneOf(idContinue, r) && !isPattern(r)
+}
+
+// IsWhitespace reports whether r separates tokens.
+func IsWhitespace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return r >= utf8.RuneSelf && unicode.Is(unicode.Pattern_White_Space, r)
+}
+
+func isPattern(r rune) bool {
+	return unicode.Is(unicode.Pattern_Syntax, r) || unicode.Is(unicode.Pattern_White_Space, r)
+}
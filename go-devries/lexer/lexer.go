@@ -2,7 +2,10 @@ package lexer
 
 import (
 	"fmt"
-	"unicode"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 //go:generate stringer -type=TokenType
@@ -16,18 +19,46 @@ const (
 
 	Ident
 	Int
+	Float
+	String
+
+	// A string containing ${...} interpolations is split into a head, up
+	// to the first "${", middles between "}" and the next "${", and a tail
+	// from the last "}" to the closing quote. The literal of each part is
+	// its unescaped text.
+	TemplateHead
+	TemplateMiddle
+	TemplateTail
 
 	Assign
+	PlusAssign
+	MinusAssign
+	AsteriskAssign
+	ForwardSlashAssign
+
 	Plus
 	Minus
 	Bang
 	Asterisk
 	ForwardSlash
+	Percent
+	Power
+
+	Ampersand
+	Pipe
+	Caret
+	Tilde
+	ShiftLeft
+	ShiftRight
 
 	LessThan
 	GreaterThan
+	LessEqual
+	GreaterEqual
 	Equal
 	NotEqual
+	And
+	Or
 
 	Comma
 	Semicolon
@@ -36,6 +67,8 @@ const (
 	RParen
 	LSquirly
 	RSquirly
+	LBracket
+	RBracket
 
 	Function
 	Let
@@ -56,6 +89,16 @@ var keywords = map[string]TokenType{
 	"return": Return,
 }
 
+// keywordLiterals maps each keyword type back to its spelling, so that
+// keyword tokens share one string instead of pointing into the input.
+var keywordLiterals = make(map[TokenType]string, len(keywords))
+
+func init() {
+	for literal, tt := range keywords {
+		keywordLiterals[tt] = literal
+	}
+}
+
 // Token is a struct for a token.
 type Token struct {
 	Type    TokenType
@@ -67,37 +110,255 @@ func (t Token) String() string {
 	return fmt.Sprintf("{Type:%s Literal:%q}", t.Type, t.Literal)
 }
 
-// Lexer is a lexer struct.
+// Lexer is a lexer struct. It scans UTF-8 input directly, and the literal of
+// every token is either a fixed string or a slice of the input, so lexing a
+// string does not allocate. A lexer reading from an io.Reader cannot slice
+// its window, which later input overwrites, so it interns literals instead:
+// it only allocates for a literal it has not returned before, for a long
+// one, or for a string with escape sequences.
 type Lexer struct {
-	input        []rune
-	position     int
-	readPosition int
+	// input is the whole input for a lexer created by NewLexer. One created
+	// by NewReaderLexer keeps its input in window instead: the part of the
+	// input from the current token on, refilled from reader on demand.
+	// position and readPosition are offsets in whichever of the two is used.
+	input        string
+	position     int // byte offset of ch
+	readPosition int // byte offset after ch
 	ch           rune
+
+	reader io.Reader
+	buf    []byte // backs window
+	window []byte
+	eof    bool
+	err    error
+
+	// literals interns the literals a reader lexer returns.
+	literals map[string]string
+
+	// templates holds, for each string interpolation being lexed, the
+	// number of unclosed braces inside its ${...}.
+	templates []int
+
+	// done is set once Next has returned the Eof token.
+	done bool
+
+	// discarded counts the bytes dropped from the front of input, so that
+	// offsets in the window can be turned into offsets in the whole input.
+	discarded int
+
+	// start and end are the offsets in the whole input of the token last
+	// returned.
+	start, end int
+
+	// newline is set if there was a line break before the token last
+	// returned.
+	newline bool
 }
 
 // NewLexer creates a new lexer from a string input.
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: []rune(input)}
+	l := &Lexer{input: input}
 	l.readChar()
 	return l
 }
 
-func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
+// NewReaderLexer creates a lexer that decodes its input from r as it goes,
+// only buffering the token being lexed. It produces the same tokens as
+// NewLexer would for the whole input. A read error ends the token stream
+// with Eof, as the end of the input would; use Err to tell the two apart.
+func NewReaderLexer(r io.Reader) *Lexer {
+	l := &Lexer{reader: r, buf: make([]byte, readerBufferSize), literals: map[string]string{}}
+	l.window = l.buf[:0]
+	l.readChar()
+	return l
+}
+
+// Err returns the first error, other than io.EOF, encountered reading the
+// input of a lexer created by NewReaderLexer.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+const readerBufferSize = 4096
+
+// A reader lexer interns literals of up to maxInternedLength bytes, and at
+// most maxInterned of them, so that the table stays small however much
+// input it reads.
+const (
+	maxInternedLength = 64
+	maxInterned       = 4096
+)
+
+// fill reads from the underlying reader, if any, until window holds the
+// whole rune at offset i or the input is exhausted. It reads no further, so
+// that on interactive input a token is complete as soon as the character
+// after it has arrived.
+func (l *Lexer) fill(i int) {
+	for l.reader != nil && !l.eof && (i >= len(l.window) || !utf8.FullRune(l.window[i:])) {
+		if len(l.window) == cap(l.window) {
+			l.compact()
+		}
+		read, err := l.reader.Read(l.window[len(l.window):cap(l.window)])
+		l.window = l.window[:len(l.window)+read]
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			l.eof = true
+		}
+	}
+}
+
+// compact moves window to the front of buf to make room after it. If the
+// window takes up more than half of buf, as a long token can, buf is doubled
+// instead, so that each byte is copied a bounded number of times however
+// long the token is.
+func (l *Lexer) compact() {
+	if 2*len(l.window) > len(l.buf) {
+		l.buf = make([]byte, 2*len(l.buf))
+	}
+	l.window = l.buf[:copy(l.buf, l.window)]
+}
+
+// discard drops the consumed part of the input window so that it does not
+// grow with the size of the input.
+func (l *Lexer) discard() {
+	if l.reader == nil || l.position == 0 {
+		return
+	}
+
+	if l.position >= len(l.window) {
+		l.discarded += len(l.window)
+		l.window = l.window[len(l.window):]
+	} else {
+		l.discarded += l.position
+		l.window = l.window[l.position:]
+	}
+	l.readPosition -= l.position
+	l.position = 0
+}
+
+// size returns the length of the input, or of the window onto it.
+func (l *Lexer) size() int {
+	if l.reader != nil {
+		return len(l.window)
+	}
+	return len(l.input)
+}
+
+// slice returns the input between two byte offsets. A reader lexer returns
+// an interned copy, since later input overwrites its window.
+func (l *Lexer) slice(start, end int) string {
+	if l.reader == nil {
+		return l.input[start:end]
+	}
+
+	b := l.window[start:end]
+	if len(b) > maxInternedLength {
+		return string(b)
+	}
+	if s, ok := l.literals[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if len(l.literals) < maxInterned {
+		l.literals[s] = s
+	}
+	return s
+}
+
+// write writes the input between two byte offsets to out.
+func (l *Lexer) write(out *strings.Builder, start, end int) {
+	if l.reader != nil {
+		out.Write(l.window[start:end])
 	} else {
-		l.ch = l.input[l.readPosition]
+		out.WriteString(l.input[start:end])
 	}
+}
+
+func (l *Lexer) readChar() {
 	l.position = l.readPosition
-	l.readPosition++
+	l.ch, l.readPosition = l.decode(l.readPosition)
+}
+
+// endOfInput is the character past the end of the input. It is not a valid
+// rune, so that a NUL byte in the input is lexed like any other character.
+const endOfInput rune = -1
+
+// decode returns the rune at byte offset i and the offset of the rune after
+// it. At the end of the input it returns endOfInput and an offset past the
+// end.
+func (l *Lexer) decode(i int) (rune, int) {
+	l.fill(i)
+	if i >= l.size() {
+		return endOfInput, i + 1
+	}
+
+	var r rune
+	var width int
+	if l.reader != nil {
+		if c := l.window[i]; c < utf8.RuneSelf {
+			return rune(c), i + 1
+		}
+		r, width = utf8.DecodeRune(l.window[i:])
+	} else {
+		if c := l.input[i]; c < utf8.RuneSelf {
+			return rune(c), i + 1
+		}
+		r, width = utf8.DecodeRuneInString(l.input[i:])
+	}
+	return r, i + width
+}
+
+// Next returns the next token, or false once the Eof token has been returned.
+// It is the iterator shape shared with the other Monkey lexers.
+func (l *Lexer) Next() (Token, bool) {
+	if l.done {
+		return Token{}, false
+	}
+
+	tok := l.NextToken()
+	l.done = tok.Type == Eof
+	return tok, true
 }
 
 // NextToken returns the next token.
 func (l *Lexer) NextToken() Token {
-	var tok Token
-
 	l.skipWhitespace()
 
+	l.start = l.offset()
+	tok := l.nextToken()
+	l.end = l.offset()
+	return tok
+}
+
+// offset returns the offset of the current character in the whole input.
+func (l *Lexer) offset() int {
+	if l.position > l.size() {
+		// Reading the end of the input moves past it.
+		return l.discarded + l.size()
+	}
+	return l.discarded + l.position
+}
+
+// Span returns the byte offsets in the input at which the token last
+// returned by NextToken starts and ends. The span of Eof is empty and at
+// the end of the input.
+func (l *Lexer) Span() (start, end int) {
+	return l.start, l.end
+}
+
+// NewlineBefore reports whether a line break separates the token last
+// returned by NextToken from the one before it.
+func (l *Lexer) NewlineBefore() bool {
+	return l.newline
+}
+
+// nextToken lexes the token at the current character, which is not white
+// space.
+func (l *Lexer) nextToken() Token {
+	var tok Token
+
 	switch l.ch {
 	case '=':
 		if l.peek() == '=' {
@@ -115,9 +376,19 @@ func (l *Lexer) NextToken() Token {
 	case ',':
 		tok = Token{Comma, ","}
 	case '+':
-		tok = Token{Plus, "+"}
+		if l.peek() == '=' {
+			l.readChar()
+			tok = Token{PlusAssign, "+="}
+		} else {
+			tok = Token{Plus, "+"}
+		}
 	case '-':
-		tok = Token{Minus, "-"}
+		if l.peek() == '=' {
+			l.readChar()
+			tok = Token{MinusAssign, "-="}
+		} else {
+			tok = Token{Minus, "-"}
+		}
 	case '!':
 		if l.peek() == '=' {
 			l.readChar()
@@ -126,30 +397,99 @@ func (l *Lexer) NextToken() Token {
 			tok = Token{Bang, "!"}
 		}
 	case '*':
-		tok = Token{Asterisk, "*"}
+		if l.peek() == '=' {
+			l.readChar()
+			tok = Token{AsteriskAssign, "*="}
+		} else if l.peek() == '*' {
+			l.readChar()
+			tok = Token{Power, "**"}
+		} else {
+			tok = Token{Asterisk, "*"}
+		}
 	case '/':
-		tok = Token{ForwardSlash, "/"}
+		if l.peek() == '=' {
+			l.readChar()
+			tok = Token{ForwardSlashAssign, "/="}
+		} else {
+			tok = Token{ForwardSlash, "/"}
+		}
+	case '%':
+		tok = Token{Percent, "%"}
 	case '<':
-		tok = Token{LessThan, "<"}
+		if l.peek() == '=' {
+			l.readChar()
+			tok = Token{LessEqual, "<="}
+		} else if l.peek() == '<' {
+			l.readChar()
+			tok = Token{ShiftLeft, "<<"}
+		} else {
+			tok = Token{LessThan, "<"}
+		}
 	case '>':
-		tok = Token{GreaterThan, ">"}
+		if l.peek() == '=' {
+			l.readChar()
+			tok = Token{GreaterEqual, ">="}
+		} else if l.peek() == '>' {
+			l.readChar()
+			tok = Token{ShiftRight, ">>"}
+		} else {
+			tok = Token{GreaterThan, ">"}
+		}
+	case '&':
+		if l.peek() == '&' {
+			l.readChar()
+			tok = Token{And, "&&"}
+		} else {
+			tok = Token{Ampersand, "&"}
+		}
+	case '|':
+		if l.peek() == '|' {
+			l.readChar()
+			tok = Token{Or, "||"}
+		} else {
+			tok = Token{Pipe, "|"}
+		}
+	case '^':
+		tok = Token{Caret, "^"}
+	case '~':
+		tok = Token{Tilde, "~"}
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		tok = Token{LSquirly, "{"}
 	case '}':
+		if n := len(l.templates); n > 0 {
+			if l.templates[n-1] == 0 {
+				tok = l.readTemplateContinuation()
+				break
+			}
+			l.templates[n-1]--
+		}
 		tok = Token{RSquirly, "}"}
-	case 0:
+	case '"':
+		tok = l.readStringLiteral()
+	case '[':
+		tok = Token{LBracket, "["}
+	case ']':
+		tok = Token{RBracket, "]"}
+	case endOfInput:
 		tok = Token{Eof, ""}
 	default:
-		if isLetter(l.ch) {
+		if IsIdentStart(l.ch) {
 			literal := l.readIdentifier()
-			tok = Token{LookupIdent(literal), literal}
+			tt := LookupIdent(literal)
+			if tt != Ident {
+				literal = keywordLiterals[tt]
+			}
+			tok = Token{tt, literal}
 			return tok
 		} else if isDigit(l.ch) {
-			number := l.readNumber()
-			tok = Token{Int, number}
+			tt, number := l.readNumber()
+			tok = Token{tt, number}
 			return tok
 		} else {
-			tok = Token{Illegal, string(l.ch)}
+			tok = Token{Illegal, l.slice(l.position, l.readPosition)}
 		}
 	}
 
@@ -158,45 +498,193 @@ func (l *Lexer) NextToken() Token {
 	return tok
 }
 
+func (l *Lexer) readStringLiteral() Token {
+	text, interpolation, ok := l.readStringPart()
+	switch {
+	case !ok:
+		return Token{Illegal, text}
+	case interpolation:
+		l.templates = append(l.templates, 0)
+		return Token{TemplateHead, text}
+	default:
+		return Token{String, text}
+	}
+}
+
+func (l *Lexer) readTemplateContinuation() Token {
+	text, interpolation, ok := l.readStringPart()
+	switch {
+	case !ok:
+		l.templates = l.templates[:len(l.templates)-1]
+		return Token{Illegal, text}
+	case interpolation:
+		return Token{TemplateMiddle, text}
+	default:
+		l.templates = l.templates[:len(l.templates)-1]
+		return Token{TemplateTail, text}
+	}
+}
+
+// readStringPart reads the unescaped text following the current character
+// up to either a closing quote or the "${" that starts an interpolation,
+// leaving the lexer on the last character it consumed. It reports false if
+// the input ends before the string does, in which case text is the raw
+// source that was read.
+func (l *Lexer) readStringPart() (text string, interpolation bool, ok bool) {
+	start := l.position
+	textStart := l.readPosition
+
+	// Text without escape sequences is sliced from the input; out is only
+	// used once an escape sequence has been seen.
+	var out strings.Builder
+	escaped := false
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case endOfInput:
+			return l.slice(start, l.position), false, false
+		case '"':
+			return l.stringText(&out, escaped, textStart), false, true
+		case '$':
+			if l.peek() == '{' {
+				text = l.stringText(&out, escaped, textStart)
+				l.readChar()
+				return text, true, true
+			}
+			if escaped {
+				out.WriteByte('$')
+			}
+		case '\\':
+			if !escaped {
+				l.write(&out, textStart, l.position)
+				escaped = true
+			}
+			l.readChar()
+			switch l.ch {
+			case endOfInput:
+				return l.slice(start, l.position), false, false
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case '"', '\\', '$':
+				out.WriteByte(byte(l.ch))
+			default:
+				out.WriteByte('\\')
+				l.write(&out, l.position, l.readPosition)
+			}
+		default:
+			if escaped {
+				l.write(&out, l.position, l.readPosition)
+			}
+		}
+	}
+}
+
+func (l *Lexer) stringText(out *strings.Builder, escaped bool, textStart int) string {
+	if escaped {
+		return out.String()
+	}
+	return l.slice(textStart, l.position)
+}
+
+// readIdentifier returns the identifier at the current position.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for IsIdentContinue(l.ch) {
 		l.readChar()
 	}
-	return string(l.input[position:l.position])
+	return l.slice(position, l.position)
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or float literal. Integers may carry a 0x, 0o
+// or 0b base prefix and any number may use _ as a digit separator; checking
+// that the literal is well formed is left to the parser.
+func (l *Lexer) readNumber() (TokenType, string) {
 	position := l.position
-	for isDigit(l.ch) {
+
+	if l.ch == '0' {
+		switch l.peek() {
+		case 'x', 'X':
+			l.readChar()
+			l.readChar()
+			l.readDigits(isHexDigit)
+			return Int, l.slice(position, l.position)
+		case 'o', 'O', 'b', 'B':
+			l.readChar()
+			l.readChar()
+			l.readDigits(isDigit)
+			return Int, l.slice(position, l.position)
+		}
+	}
+
+	tt := Int
+	l.readDigits(isDigit)
+
+	if l.ch == '.' && isDigit(l.peek()) {
+		tt = Float
 		l.readChar()
+		l.readDigits(isDigit)
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peek()
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekN(2))) {
+			tt = Float
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits(isDigit)
+		}
 	}
 
-	return string(l.input[position:l.position])
+	return tt, l.slice(position, l.position)
+}
+
+func (l *Lexer) readDigits(valid func(rune) bool) {
+	for valid(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
 }
 
 func (l *Lexer) skipWhitespace() {
-	for unicode.IsSpace(l.ch) {
+	l.newline = false
+	for IsWhitespace(l.ch) {
+		if l.ch == '\n' {
+			l.newline = true
+		}
 		l.readChar()
+		l.discard()
 	}
+	l.discard()
 }
 
 func (l *Lexer) peek() rune {
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.readPosition]
-	}
+	return l.peekN(1)
 }
 
-func isLetter(r rune) bool {
-	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || r == '_'
+// peekN returns the rune n positions after the current one.
+func (l *Lexer) peekN(n int) rune {
+	r, next := rune(0), l.readPosition
+	for ; n > 0; n-- {
+		r, next = l.decode(next)
+	}
+	return r
 }
 
 func isDigit(r rune) bool {
 	return '0' <= r && r <= '9'
 }
 
+func isHexDigit(r rune) bool {
+	return isDigit(r) || ('a' <= r && r <= 'f') || ('A' <= r && r <= 'F')
+}
+
 // LookupIdent returns the token type from string.
 func LookupIdent(ident string) TokenType {
 	if tt, ok := keywords[ident]; ok {
@@ -205,3 +693,13 @@ func LookupIdent(ident string) TokenType {
 
 	return Ident
 }
+
+// Keywords returns the keywords of the language, sorted.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
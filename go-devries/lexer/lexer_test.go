@@ -1,7 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestNextToken(t *testing.T) {
@@ -24,6 +29,10 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+[1, 2];
+x += 1; x -= 1; x *= 2; x /= 2;
+a <= b >= c && d || e;
+a % b ** c & d | e ^ ~f << g >> h;
 `
 
 	tests := []struct {
@@ -103,6 +112,55 @@ if (5 < 10) {
 		{NotEqual, "!="},
 		{Int, "9"},
 		{Semicolon, ";"},
+		{LBracket, "["},
+		{Int, "1"},
+		{Comma, ","},
+		{Int, "2"},
+		{RBracket, "]"},
+		{Semicolon, ";"},
+		{Ident, "x"},
+		{PlusAssign, "+="},
+		{Int, "1"},
+		{Semicolon, ";"},
+		{Ident, "x"},
+		{MinusAssign, "-="},
+		{Int, "1"},
+		{Semicolon, ";"},
+		{Ident, "x"},
+		{AsteriskAssign, "*="},
+		{Int, "2"},
+		{Semicolon, ";"},
+		{Ident, "x"},
+		{ForwardSlashAssign, "/="},
+		{Int, "2"},
+		{Semicolon, ";"},
+		{Ident, "a"},
+		{LessEqual, "<="},
+		{Ident, "b"},
+		{GreaterEqual, ">="},
+		{Ident, "c"},
+		{And, "&&"},
+		{Ident, "d"},
+		{Or, "||"},
+		{Ident, "e"},
+		{Semicolon, ";"},
+		{Ident, "a"},
+		{Percent, "%"},
+		{Ident, "b"},
+		{Power, "**"},
+		{Ident, "c"},
+		{Ampersand, "&"},
+		{Ident, "d"},
+		{Pipe, "|"},
+		{Ident, "e"},
+		{Caret, "^"},
+		{Tilde, "~"},
+		{Ident, "f"},
+		{ShiftLeft, "<<"},
+		{Ident, "g"},
+		{ShiftRight, ">>"},
+		{Ident, "h"},
+		{Semicolon, ";"},
 		{Eof, ""},
 	}
 
@@ -120,3 +178,434 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{"42", []Token{{Int, "42"}}},
+		{"1_000_000", []Token{{Int, "1_000_000"}}},
+		{"0x1F", []Token{{Int, "0x1F"}}},
+		{"0XdeadBEEF", []Token{{Int, "0XdeadBEEF"}}},
+		{"0o17", []Token{{Int, "0o17"}}},
+		{"0b1010_0101", []Token{{Int, "0b1010_0101"}}},
+		{"3.14", []Token{{Float, "3.14"}}},
+		{"1_000.000_1", []Token{{Float, "1_000.000_1"}}},
+		{"1e10", []Token{{Float, "1e10"}}},
+		{"6.02E+23", []Token{{Float, "6.02E+23"}}},
+		{"1.5e-3", []Token{{Float, "1.5e-3"}}},
+		{"1.", []Token{{Int, "1"}, {Illegal, "."}}},
+		{"1e", []Token{{Int, "1"}, {Ident, "e"}}},
+		{"2e-x", []Token{{Int, "2"}, {Ident, "e"}, {Minus, "-"}, {Ident, "x"}}},
+		{"1.5.5", []Token{{Float, "1.5"}, {Illegal, "."}, {Int, "5"}}},
+		{"0b102", []Token{{Int, "0b102"}}},
+		{"0xg", []Token{{Int, "0x"}, {Ident, "g"}}},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+
+		for i, expected := range append(tt.expected, Token{Eof, ""}) {
+			tok := l.NextToken()
+			if tok != expected {
+				t.Fatalf("%q: token %d wrong. expected=%v, got=%v", tt.input, i, expected, tok)
+			}
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{`"foobar"`, []Token{{String, "foobar"}}},
+		{`"foo bar"`, []Token{{String, "foo bar"}}},
+		{`""`, []Token{{String, ""}}},
+		{`"a\"b\\c\n\t\r"`, []Token{{String, "a\"b\\c\n\t\r"}}},
+		{`"costs $5 \${x} \q"`, []Token{{String, "costs $5 ${x} \\q"}}},
+		{`"hello ${name}!"`, []Token{
+			{TemplateHead, "hello "},
+			{Ident, "name"},
+			{TemplateTail, "!"},
+		}},
+		{`"hello ${user[0]}, you have ${len(items)} items"`, []Token{
+			{TemplateHead, "hello "},
+			{Ident, "user"},
+			{LBracket, "["},
+			{Int, "0"},
+			{RBracket, "]"},
+			{TemplateMiddle, ", you have "},
+			{Ident, "len"},
+			{LParen, "("},
+			{Ident, "items"},
+			{RParen, ")"},
+			{TemplateTail, " items"},
+		}},
+		{`"${a}${b}"`, []Token{
+			{TemplateHead, ""},
+			{Ident, "a"},
+			{TemplateMiddle, ""},
+			{Ident, "b"},
+			{TemplateTail, ""},
+		}},
+		{`"${fn() { 1 }()}"`, []Token{
+			{TemplateHead, ""},
+			{Function, "fn"},
+			{LParen, "("},
+			{RParen, ")"},
+			{LSquirly, "{"},
+			{Int, "1"},
+			{RSquirly, "}"},
+			{LParen, "("},
+			{RParen, ")"},
+			{TemplateTail, ""},
+		}},
+		{`"a${"b${c}"}d"`, []Token{
+			{TemplateHead, "a"},
+			{TemplateHead, "b"},
+			{Ident, "c"},
+			{TemplateTail, ""},
+			{TemplateTail, "d"},
+		}},
+		{`"abc`, []Token{{Illegal, `"abc`}}},
+		{`"abc${x}de`, []Token{
+			{TemplateHead, "abc"},
+			{Ident, "x"},
+			{Illegal, "}de"},
+		}},
+		{`"a\`, []Token{{Illegal, `"a\`}}},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+
+		for i, expected := range append(tt.expected, Token{Eof, ""}) {
+			tok := l.NextToken()
+			if tok != expected {
+				t.Fatalf("%s: token %d wrong. expected=%v, got=%v", tt.input, i, expected, tok)
+			}
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{`let café = "naïve ☃";`, []Token{
+			{Let, "let"},
+			{Ident, "café"},
+			{Assign, "="},
+			{String, "naïve ☃"},
+			{Semicolon, ";"},
+		}},
+		{"x1 = 名前_2 + _ü", []Token{
+			{Ident, "x1"},
+			{Assign, "="},
+			{Ident, "名前_2"},
+			{Plus, "+"},
+			{Ident, "_ü"},
+		}},
+		// A combining mark continues an identifier but cannot start one.
+		{"e\u0301t\u0301e \u0301", []Token{
+			{Ident, "e\u0301t\u0301e"},
+			{Illegal, "\u0301"},
+		}},
+		// So does a non-ASCII digit.
+		{"x٣ ٣", []Token{
+			{Ident, "x٣"},
+			{Illegal, "٣"},
+		}},
+		{"€ ∑x", []Token{
+			{Illegal, "€"},
+			{Illegal, "∑"},
+			{Ident, "x"},
+		}},
+		// Pattern_White_Space, which excludes the no-break space.
+		{"a\u2028b\u0085c\u200ed\u00a0e", []Token{
+			{Ident, "a"},
+			{Ident, "b"},
+			{Ident, "c"},
+			{Ident, "d"},
+			{Illegal, "\u00a0"},
+			{Ident, "e"},
+		}},
+		{`"${ünï} → ${"ω"}"`, []Token{
+			{TemplateHead, ""},
+			{Ident, "ünï"},
+			{TemplateMiddle, " → "},
+			{String, "ω"},
+			{TemplateTail, ""},
+		}},
+		// A NUL byte is an illegal character, not the end of the input.
+		{"a\x000 \"b\x00\"", []Token{
+			{Ident, "a"},
+			{Illegal, "\x00"},
+			{Int, "0"},
+			{String, "b\x00"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+		expected := append(tt.expected, Token{Eof, ""})
+		for i, exp := range expected {
+			if tok := l.NextToken(); tok != exp {
+				t.Fatalf("%q: token %d wrong. expected=%v, got=%v", tt.input, i, exp, tok)
+			}
+		}
+	}
+}
+
+func TestReaderLexerMatchesLexer(t *testing.T) {
+	inputs := []string{
+		"",
+		"let five = 5;\nlet add = fn(x, y) { x + y; };\nadd(five, 10);",
+		"a <= b >= c && d || e != f == g ** h % i",
+		"0x1F + 1_000.5e-3 * 0b11 + 1e",
+		`"hello ${user[0]}, you have ${len(items)} items" "esc\"aped\n"`,
+		"héllo wörld 日本 \xff\xfe ¿?",
+		"let café = \"naïve ☃ ${名前}\";\u2028x٣\u00a0",
+		"\"unterminated ${x",
+		"x\x00y",
+	}
+
+	for _, input := range inputs {
+		readers := map[string]io.Reader{
+			"plain":    strings.NewReader(input),
+			"one byte": iotest.OneByteReader(strings.NewReader(input)),
+			"data err": iotest.DataErrReader(strings.NewReader(input)),
+		}
+
+		for name, r := range readers {
+			want := NewLexer(input)
+			got := NewReaderLexer(r)
+
+			for i := 0; ; i++ {
+				wantTok := want.NextToken()
+				gotTok := got.NextToken()
+				if wantTok != gotTok {
+					t.Fatalf("%s reader, %q: token %d wrong. expected=%v, got=%v", name, input, i, wantTok, gotTok)
+				}
+				if wantTok.Type == Eof {
+					break
+				}
+			}
+
+			if err := got.Err(); err != nil {
+				t.Errorf("%s reader, %q: unexpected error %v", name, input, err)
+			}
+		}
+	}
+}
+
+func TestReaderLexerError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	r := io.MultiReader(strings.NewReader("let x = 5; le"), iotest.ErrReader(errBroken))
+
+	l := NewReaderLexer(r)
+
+	expected := []Token{
+		{Let, "let"},
+		{Ident, "x"},
+		{Assign, "="},
+		{Int, "5"},
+		{Semicolon, ";"},
+		{Ident, "le"},
+		{Eof, ""},
+	}
+	for i, tt := range expected {
+		if tok := l.NextToken(); tok != tt {
+			t.Fatalf("token %d wrong. expected=%v, got=%v", i, tt, tok)
+		}
+	}
+
+	if !errors.Is(l.Err(), errBroken) {
+		t.Errorf("l.Err() wrong. expected=%v, got=%v", errBroken, l.Err())
+	}
+}
+
+func TestReaderLexerPipe(t *testing.T) {
+	r, w := io.Pipe()
+	tokens := make(chan Token)
+	go func() {
+		defer close(tokens)
+		l := NewReaderLexer(r)
+		for tok, ok := l.Next(); ok; tok, ok = l.Next() {
+			tokens <- tok
+		}
+	}()
+
+	// Each token must arrive once the character after it has been written,
+	// without waiting for more input.
+	steps := []struct {
+		input    string
+		expected []Token
+	}{
+		{"let x = 5\n", []Token{{Let, "let"}, {Ident, "x"}, {Assign, "="}, {Int, "5"}}},
+		{"\xc3", nil},
+		{"\xa9\n", []Token{{Ident, "é"}}},
+		{"x", nil},
+	}
+	for _, step := range steps {
+		if _, err := io.WriteString(w, step.input); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range step.expected {
+			select {
+			case tok := <-tokens:
+				if tok != expected {
+					t.Errorf("after %q: expected %v, got %v", step.input, expected, tok)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("no token %v after %q", expected, step.input)
+			}
+		}
+	}
+
+	w.Close()
+	for _, expected := range []Token{{Ident, "x"}, {Eof, ""}} {
+		if tok := <-tokens; tok != expected {
+			t.Errorf("at the end of the input: expected %v, got %v", expected, tok)
+		}
+	}
+}
+
+func TestReaderLexerBuffering(t *testing.T) {
+	input := strings.Repeat("let someIdentifier = 12345 + other;\n", 10000)
+	l := NewReaderLexer(strings.NewReader(input))
+
+	for tok := l.NextToken(); tok.Type != Eof; tok = l.NextToken() {
+		if len(l.buf) > readerBufferSize {
+			t.Fatalf("input buffer grew to %d bytes", len(l.buf))
+		}
+	}
+}
+
+func TestReaderLexerLongToken(t *testing.T) {
+	text := strings.Repeat("é", 1<<20)
+	input := `"` + text + `" + ` + strings.Repeat("x", 3*readerBufferSize)
+	l := NewReaderLexer(iotest.HalfReader(strings.NewReader(input)))
+
+	expected := []Token{
+		{String, text},
+		{Plus, "+"},
+		{Ident, strings.Repeat("x", 3*readerBufferSize)},
+		{Eof, ""},
+	}
+	for i, tt := range expected {
+		if tok := l.NextToken(); tok != tt {
+			t.Fatalf("token %d wrong. expected=%.20v, got=%.20v", i, tt, tok)
+		}
+	}
+
+	// The buffer doubles as a token outgrows it, so it ends up no more than
+	// twice the size of the longest token.
+	if len(l.buf) > 2*len(text) {
+		t.Errorf("input buffer grew to %d bytes for a %d byte token", len(l.buf), len(text))
+	}
+}
+
+func TestLexerDoesNotAllocate(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		l := NewLexer(benchmarkProgram)
+		for tok := l.NextToken(); tok.Type != Eof; tok = l.NextToken() {
+		}
+	})
+	// The lexer itself and its template stack.
+	if allocs > 2 {
+		t.Errorf("lexing allocated %v times, want at most 2", allocs)
+	}
+}
+
+func TestReaderLexerAllocations(t *testing.T) {
+	allocs := func(input string) float64 {
+		return testing.AllocsPerRun(10, func() {
+			l := NewReaderLexer(strings.NewReader(input))
+			for tok := l.NextToken(); tok.Type != Eof; tok = l.NextToken() {
+			}
+		})
+	}
+
+	// Literals the lexer has seen before are interned, so a program that
+	// repeats itself costs no more than one copy of it.
+	once, many := allocs(benchmarkProgram), allocs(strings.Repeat(benchmarkProgram, 100))
+	if many != once {
+		t.Errorf("lexing 100 copies of a program allocated %v times, want %v as for one", many, once)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tokens := []Token{
+		{Ident, "x"},
+		{Plus, "+"},
+		{Int, "1"},
+	}
+	r := NewReplay(tokens)
+
+	expected := append(tokens, Token{Eof, ""}, Token{Eof, ""})
+	for i, tt := range expected {
+		if tok := r.NextToken(); tok != tt {
+			t.Fatalf("token %d wrong. expected=%v, got=%v", i, tt, tok)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	l := NewLexer("let x = 5;")
+
+	expected := []Token{
+		{Let, "let"},
+		{Ident, "x"},
+		{Assign, "="},
+		{Int, "5"},
+		{Semicolon, ";"},
+		{Eof, ""},
+	}
+	for i, tt := range expected {
+		tok, ok := l.Next()
+		if !ok || tok != tt {
+			t.Fatalf("token %d wrong. expected=%v, got=%v (%v)", i, tt, tok, ok)
+		}
+	}
+
+	if tok, ok := l.Next(); ok {
+		t.Errorf("Next returned %v after Eof", tok)
+	}
+}
+
+func TestSpan(t *testing.T) {
+	input := "let s = \"a${x}é\";\n  x >= 10 \"open"
+	expected := [][2]int{
+		{0, 3}, {4, 5}, {6, 7}, {8, 12}, {12, 13}, {13, 17}, {17, 18},
+		{21, 22}, {23, 25}, {26, 28}, {29, 34}, {34, 34},
+	}
+
+	lexers := map[string]*Lexer{
+		"string":   NewLexer(input),
+		"one byte": NewReaderLexer(iotest.OneByteReader(strings.NewReader(input))),
+	}
+	for name, l := range lexers {
+		for i, exp := range expected {
+			tok := l.NextToken()
+			if start, end := l.Span(); start != exp[0] || end != exp[1] {
+				t.Errorf("%s: token %d %v: expected span %d-%d, got %d-%d", name, i, tok, exp[0], exp[1], start, end)
+			}
+		}
+	}
+}
+
+func TestNewlineBefore(t *testing.T) {
+	l := NewLexer("a b\nc \r\n\td\"\n\"e\n")
+	expected := []bool{false, false, true, true, false, false, true}
+
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if got := l.NewlineBefore(); got != exp {
+			t.Errorf("token %d %v: expected NewlineBefore %t, got %t", i, tok, exp, got)
+		}
+	}
+}
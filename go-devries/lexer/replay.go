@@ -0,0 +1,23 @@
+package lexer
+
+// Replay is a token source that returns a fixed sequence of tokens, such as a
+// cached or generated token stream, and then Eof tokens forever after.
+type Replay struct {
+	tokens []Token
+	pos    int
+}
+
+// NewReplay returns a Replay over tokens. The tokens need not end with Eof.
+func NewReplay(tokens []Token) *Replay {
+	return &Replay{tokens: tokens}
+}
+
+// NextToken returns the next token.
+func (r *Replay) NextToken() Token {
+	if r.pos >= len(r.tokens) {
+		return Token{Eof, ""}
+	}
+	tok := r.tokens[r.pos]
+	r.pos++
+	return tok
+}
@@ -0,0 +1,395 @@
+// Package lineedit is a small line editor for terminals, with history and
+// tab completion. It understands the usual Emacs-style keys:
+//
+//	left, right, ctrl-b, ctrl-f   move by a character
+//	home, end, ctrl-a, ctrl-e     move to the start or end of the line
+//	backspace, delete, ctrl-d     delete a character
+//	ctrl-k, ctrl-u, ctrl-w        delete to the end, the start or a word back
+//	up, down, ctrl-p, ctrl-n      move through the history
+//	tab                           complete the word before the cursor
+//	ctrl-c                        abandon the line
+//	ctrl-d on an empty line       end of input
+//
+// The terminal is only in raw mode while a line is being read, so output
+// written between lines appears as usual. Raw mode is implemented for Linux;
+// elsewhere Open fails and callers fall back to reading lines plainly.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrNotTerminal is returned by Open when its input is not a terminal.
+var ErrNotTerminal = errors.New("not a terminal")
+
+// ErrInterrupted is returned by ReadLine when the line is abandoned with
+// ctrl-c.
+var ErrInterrupted = errors.New("interrupted")
+
+// MaxHistory is the most lines kept in the history, and in its file.
+const MaxHistory = 1000
+
+// Completer returns the candidates for completing the word that ends at
+// byte offset pos of line, and the offset at which that word starts.
+type Completer func(line string, pos int) (start int, candidates []string)
+
+// Editor reads lines from a terminal.
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// raw puts the terminal into raw mode and returns a function that
+	// restores it. It is nil when the input is not a terminal, as in tests.
+	raw func() (restore func() error, err error)
+
+	// Complete, if set, is called when tab is pressed.
+	Complete Completer
+
+	history     []string
+	historyFile string
+}
+
+// Open returns an editor that reads keys from the terminal in and echoes
+// to out. It fails with ErrNotTerminal if in is not a terminal.
+func Open(in *os.File, out io.Writer) (*Editor, error) {
+	fd := int(in.Fd())
+	if !isTerminal(fd) {
+		return nil, ErrNotTerminal
+	}
+
+	e := newEditor(in, out)
+	e.raw = func() (func() error, error) { return makeRaw(fd) }
+	return e, nil
+}
+
+func newEditor(in io.Reader, out io.Writer) *Editor {
+	return &Editor{in: bufio.NewReader(in), out: out}
+}
+
+// LoadHistory reads the history from file, one line per entry, and appends
+// every line added with AddHistory to it from then on. A missing file is
+// not an error.
+func (e *Editor) LoadHistory(file string) error {
+	e.historyFile = file
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+		return e.rewriteHistory()
+	}
+	return nil
+}
+
+// AddHistory adds line to the history, unless it is empty or repeats the
+// previous entry.
+func (e *Editor) AddHistory(line string) error {
+	if line == "" || strings.Contains(line, "\n") {
+		return nil
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return nil
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[1:]
+		return e.rewriteHistory()
+	}
+
+	if e.historyFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (e *Editor) rewriteHistory() error {
+	if e.historyFile == "" {
+		return nil
+	}
+	return os.WriteFile(e.historyFile, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+}
+
+// line is the state of the line being edited.
+type line struct {
+	prompt string
+	buf    []rune
+	pos    int
+
+	// history is the index of the history entry shown, or len(history)
+	// for the line being typed, which is kept in draft meanwhile.
+	history int
+	draft   []rune
+
+	// tabbed is set after a tab that could not complete anything, so that
+	// a second tab lists the candidates.
+	tabbed bool
+}
+
+// ReadLine shows prompt and returns the line typed, without the newline. It
+// returns io.EOF at the end of the input or on ctrl-d on an empty line, and
+// ErrInterrupted on ctrl-c.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	l := &line{prompt: prompt, history: len(e.history)}
+	e.refresh(l)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err == io.EOF && len(l.buf) > 0 {
+			// Input that ends without a newline still has a last line.
+			e.write("\r\n")
+			return string(l.buf), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		tabbed := l.tabbed
+		l.tabbed = false
+
+		switch r {
+		case '\r', '\n':
+			e.write("\r\n")
+			return string(l.buf), nil
+		case ctrl('c'):
+			e.write("^C\r\n")
+			return "", ErrInterrupted
+		case ctrl('d'):
+			if len(l.buf) == 0 {
+				e.write("\r\n")
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case 127, ctrl('h'):
+			l.delete(l.pos-1, l.pos)
+		case ctrl('a'):
+			l.pos = 0
+		case ctrl('e'):
+			l.pos = len(l.buf)
+		case ctrl('b'):
+			l.move(-1)
+		case ctrl('f'):
+			l.move(1)
+		case ctrl('k'):
+			l.delete(l.pos, len(l.buf))
+		case ctrl('u'):
+			l.delete(0, l.pos)
+		case ctrl('w'):
+			l.delete(l.wordStart(), l.pos)
+		case ctrl('p'):
+			e.browse(l, -1)
+		case ctrl('n'):
+			e.browse(l, 1)
+		case '\t':
+			e.complete(l, tabbed)
+		case 27:
+			e.escape(l)
+		default:
+			if unicode.IsPrint(r) {
+				l.insert(r)
+			}
+		}
+		e.refresh(l)
+	}
+}
+
+func ctrl(c rune) rune {
+	return c & 0x1f
+}
+
+// escape handles the escape sequences of the arrow, home, end and delete
+// keys, and ignores any other.
+func (e *Editor) escape(l *line) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+
+	// The sequence is any number of parameter bytes followed by a final
+	// byte in the range @ to ~.
+	var params strings.Builder
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return
+		}
+		if r >= '@' && r <= '~' {
+			break
+		}
+		params.WriteRune(r)
+	}
+
+	switch {
+	case r == 'A':
+		e.browse(l, -1)
+	case r == 'B':
+		e.browse(l, 1)
+	case r == 'C':
+		l.move(1)
+	case r == 'D':
+		l.move(-1)
+	case r == 'H', r == '~' && (params.String() == "1" || params.String() == "7"):
+		l.pos = 0
+	case r == 'F', r == '~' && (params.String() == "4" || params.String() == "8"):
+		l.pos = len(l.buf)
+	case r == '~' && params.String() == "3":
+		l.delete(l.pos, l.pos+1)
+	}
+}
+
+// browse replaces the line with the history entry delta steps away.
+func (e *Editor) browse(l *line, delta int) {
+	next := l.history + delta
+	if next < 0 || next > len(e.history) {
+		return
+	}
+
+	if l.history == len(e.history) {
+		l.draft = append([]rune(nil), l.buf...)
+	}
+	l.history = next
+	if next == len(e.history) {
+		l.buf = append([]rune(nil), l.draft...)
+	} else {
+		l.buf = []rune(e.history[next])
+	}
+	l.pos = len(l.buf)
+}
+
+// complete extends the word before the cursor as far as all candidates
+// agree, or lists the candidates if that is no further and tabbed reports
+// that tab was also the previous key.
+func (e *Editor) complete(l *line, tabbed bool) {
+	if e.Complete == nil {
+		return
+	}
+
+	text := string(l.buf)
+	pos := len(string(l.buf[:l.pos]))
+	start, candidates := e.Complete(text, pos)
+	if len(candidates) == 0 || start < 0 || start > pos {
+		return
+	}
+
+	word := text[start:pos]
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+		for _, r := range prefix[len(word):] {
+			l.insert(r)
+		}
+		if len(candidates) == 1 && l.pos == len(l.buf) {
+			l.insert(' ')
+		}
+		return
+	}
+
+	if !tabbed {
+		l.tabbed = true
+		return
+	}
+	e.write("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func (l *line) insert(r rune) {
+	l.buf = append(l.buf[:l.pos], append([]rune{r}, l.buf[l.pos:]...)...)
+	l.pos++
+}
+
+// delete removes the runes from start up to end, clamped to the line.
+func (l *line) delete(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(l.buf) {
+		end = len(l.buf)
+	}
+	if start >= end {
+		return
+	}
+	l.buf = append(l.buf[:start], l.buf[end:]...)
+	if l.pos > end {
+		l.pos -= end - start
+	} else if l.pos > start {
+		l.pos = start
+	}
+}
+
+func (l *line) move(delta int) {
+	l.pos += delta
+	if l.pos < 0 {
+		l.pos = 0
+	}
+	if l.pos > len(l.buf) {
+		l.pos = len(l.buf)
+	}
+}
+
+// wordStart returns the start of the word before the cursor, skipping the
+// spaces before it.
+func (l *line) wordStart() int {
+	i := l.pos
+	for i > 0 && unicode.IsSpace(l.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(l.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// refresh redraws the line and puts the cursor in place.
+func (e *Editor) refresh(l *line) {
+	var out strings.Builder
+	out.WriteString("\r" + l.prompt + string(l.buf) + "\x1b[K\r")
+	if n := len([]rune(l.prompt)) + l.pos; n > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", n)
+	}
+	e.write(out.String())
+}
+
+func (e *Editor) write(s string) {
+	io.WriteString(e.out, s)
+}
@@ -0,0 +1,172 @@
+package lineedit
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// read returns the lines an editor reads from keys, with the given history.
+func read(keys string, history ...string) ([]string, error) {
+	e := newEditor(strings.NewReader(keys), io.Discard)
+	e.history = history
+
+	var lines []string
+	for {
+		line, err := e.ReadLine("> ")
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		keys     string
+		history  []string
+		expected []string
+	}{
+		{"let x = 1\r", nil, []string{"let x = 1"}},
+		{"one\ntwo", nil, []string{"one", "two"}},
+		{"abd\x7f\x7fbc\r", nil, []string{"abc"}},
+		{"ac\x1b[Db\r", nil, []string{"abc"}},
+		{"bc\x01a\x05d\r", nil, []string{"abcd"}},
+		{"bc\x1b[Ha\x1b[Fd\r", nil, []string{"abcd"}},
+		{"bc\x1bOHa\x1b[4~d\r", nil, []string{"abcd"}},
+		{"abxc\x1b[D\x1b[D\x1b[3~\r", nil, []string{"abc"}},
+		{"abxc\x02\x02\x04\x06x\r", nil, []string{"abcx"}},
+		{"abc def\x01\x06\x0b\r", nil, []string{"a"}},
+		{"abc def\x02\x15\r", nil, []string{"f"}},
+		{"let  x = 1\x17\x17\r", nil, []string{"let  x "}},
+		{"\x1b[A\r", []string{"first", "second"}, []string{"second"}},
+		{"\x10\x10\x10\r", []string{"first", "second"}, []string{"first"}},
+		{"draft\x1b[A\x1b[A\x1b[B\x1b[B\r", []string{"first", "second"}, []string{"draft"}},
+		{"x\x1b[A!\r", []string{"first"}, []string{"first!"}},
+		{"é\x1b[Dà\r", nil, []string{"àé"}},
+		{"a\x1b[5~b\x1bxc\r", nil, []string{"abc"}},
+	}
+
+	for _, tt := range tests {
+		lines, err := read(tt.keys, tt.history...)
+		if err != io.EOF {
+			t.Errorf("%q: expected io.EOF, got %v", tt.keys, err)
+		}
+		if strings.Join(lines, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%q: expected=%q, got=%q", tt.keys, tt.expected, lines)
+		}
+	}
+}
+
+func TestEndOfInput(t *testing.T) {
+	lines, err := read("one\r\x04two\r")
+	if err != io.EOF || len(lines) != 1 {
+		t.Errorf("expected one line and io.EOF, got %q and %v", lines, err)
+	}
+
+	if _, err := read("abc\x03"); err != ErrInterrupted {
+		t.Errorf("expected ErrInterrupted, got %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	words := []string{"let", "len", "length", "puts"}
+	complete := func(line string, pos int) (int, []string) {
+		start := strings.LastIndex(line[:pos], " ") + 1
+		var candidates []string
+		for _, w := range words {
+			if strings.HasPrefix(w, line[start:pos]) {
+				candidates = append(candidates, w)
+			}
+		}
+		return start, candidates
+	}
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"x = p\t\r", "x = puts "},
+		{"le\tng\t\r", "length "},
+		{"le\tx\r", "lex"},
+		{"p\x01\t\r", "p"},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		e := newEditor(strings.NewReader(tt.keys), &out)
+		e.Complete = complete
+		got, err := e.ReadLine("> ")
+		if err != nil || got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q (%v)", tt.keys, tt.expected, got, err)
+		}
+	}
+
+	// A second tab lists the candidates when there is nothing to add.
+	var out strings.Builder
+	e := newEditor(strings.NewReader("le\t\t\r"), &out)
+	e.Complete = complete
+	if _, err := e.ReadLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "let  len  length") {
+		t.Errorf("expected the candidates to be listed, got %q", out.String())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(file, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := newEditor(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(file); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"one", "one", "", "two"} {
+		if err := e.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new session sees the lines of the previous one.
+	e = newEditor(strings.NewReader("\x1b[A\x1b[A\x1b[A\r"), io.Discard)
+	if err := e.LoadHistory(file); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(e.history, "|"); got != "old|one|two" {
+		t.Errorf("expected history old|one|two, got %q", got)
+	}
+	if got, err := e.ReadLine("> "); err != nil || got != "old" {
+		t.Errorf("expected old, got %q (%v)", got, err)
+	}
+
+	if err := e.LoadHistory(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("expected a missing file to be ignored, got %v", err)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	e := newEditor(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(file); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < MaxHistory+10; i++ {
+		if err := e.AddHistory(strings.Repeat("x", i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != MaxHistory || len(lines[0]) != 11 {
+		t.Errorf("expected the last %d lines, got %d starting with %q", MaxHistory, len(lines), lines[0])
+	}
+}
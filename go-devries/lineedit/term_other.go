@@ -0,0 +1,11 @@
+//go:build !linux
+
+package lineedit
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func() error, error) {
+	return nil, ErrNotTerminal
+}
@@ -0,0 +1,89 @@
+package object
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// Environment maps names to values. Every function call and every block
+// gets its own environment, enclosing the one it was created in.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+	out   io.Writer
+}
+
+// NewEnvironment creates an empty top-level environment.
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+// NewEnclosedEnvironment creates an empty environment nested in outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get looks name up in this environment and then in the enclosing ones.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name in this environment, shadowing any outer binding.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign rebinds name in the innermost environment that declares it. It
+// reports false, and binds nothing, if name was never declared.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names bound in this environment and the enclosing ones,
+// sorted.
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetOutput makes puts write to w in this environment and the ones it
+// encloses, rather than to standard output.
+func (e *Environment) SetOutput(w io.Writer) {
+	e.out = w
+}
+
+// Output returns where puts writes: the writer set on this environment or
+// the nearest enclosing one, or standard output if there is none.
+func (e *Environment) Output() io.Writer {
+	for env := e; env != nil; env = env.outer {
+		if env.out != nil {
+			return env.out
+		}
+	}
+	return os.Stdout
+}
@@ -0,0 +1,160 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"monkey/ast"
+)
+
+type ObjectType string
+
+const (
+	IntegerObj     ObjectType = "INTEGER"
+	FloatObj       ObjectType = "FLOAT"
+	BooleanObj     ObjectType = "BOOLEAN"
+	NullObj        ObjectType = "NULL"
+	ReturnValueObj ObjectType = "RETURN_VALUE"
+	ErrorObj       ObjectType = "ERROR"
+	FunctionObj    ObjectType = "FUNCTION"
+	ArrayObj       ObjectType = "ARRAY"
+	StringObj      ObjectType = "STRING"
+	BuiltinObj     ObjectType = "BUILTIN"
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return IntegerObj }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+// BigInteger holds an integer that does not fit in an int64. It reports the
+// same type as Integer, so the promotion is invisible to Monkey programs.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType { return IntegerObj }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FloatObj }
+
+// Inspect always shows a fractional part or exponent, so that a float with
+// an integral value is not mistaken for an Integer.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BooleanObj }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NullObj }
+func (n *Null) Inspect() string  { return "null" }
+
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return ReturnValueObj }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+type Error struct {
+	Message string
+
+	// Suggestion, if set, is what was probably meant, such as the name
+	// closest to one that was not found.
+	Suggestion string
+}
+
+func (e *Error) Type() ObjectType { return ErrorObj }
+func (e *Error) Inspect() string {
+	if e.Suggestion != "" {
+		return "ERROR: " + e.Message + " (did you mean " + e.Suggestion + "?)"
+	}
+	return "ERROR: " + e.Message
+}
+
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FunctionObj }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ArrayObj }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return StringObj }
+func (s *String) Inspect() string  { return s.Value }
+
+// BuiltinFunction implements a builtin. It is passed the environment of the
+// call, for builtins such as puts that need more than their arguments.
+type BuiltinFunction func(env *Environment, args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BuiltinObj }
+func (b *Builtin) Inspect() string  { return "builtin function" }
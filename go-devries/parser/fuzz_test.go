@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+)
+
+// FuzzParser checks that parsing any input, and printing the tree that
+// results, never panics, however many syntax errors the input has. The
+// seed corpus in testdata/fuzz comes from the inputs of the other tests.
+func FuzzParser(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.NewLexer(input))
+		program := p.ParseProgram()
+
+		for _, stmt := range program.Statements {
+			if stmt == nil {
+				t.Fatalf("nil statement in program")
+			}
+		}
+		_ = program.String()
+		_ = ast.SExpr(program)
+	})
+}
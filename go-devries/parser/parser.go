@@ -1,22 +1,64 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"monkey/ast"
+	"monkey/diag"
 	"monkey/lexer"
 )
 
 type prefixParseFn func() ast.Expression
 type infixParseFn func(ast.Expression) ast.Expression
 
+// TokenSource is anything the parser can read tokens from: a *lexer.Lexer,
+// a *lexer.Replay or an adapter around another lexer. Once its input is
+// exhausted a TokenSource must keep returning lexer.Eof tokens.
+type TokenSource interface {
+	NextToken() lexer.Token
+}
+
+// spanner is implemented by token sources that know where in the input
+// each token is, as *lexer.Lexer does. The diagnostics of a parser reading
+// from any other source have no spans.
+type spanner interface {
+	Span() (start, end int)
+}
+
+// newliner is implemented by token sources that know where lines break, as
+// *lexer.Lexer does.
+type newliner interface {
+	NewlineBefore() bool
+}
+
 type Parser struct {
-	l      *lexer.Lexer
+	l      TokenSource
 	Errors []string
 
-	curToken  lexer.Token
+	// Diagnostics holds the same errors as Errors, with the span of the
+	// token each was found at and any notes, and warnings about code that
+	// parses but is probably not what was meant.
+	Diagnostics []diag.Diagnostic
+
+	curToken lexer.Token
+	curSpan  diag.Span
+
+	// peekToken is read from l only when it is first needed, so that a
+	// statement can be parsed without reading any token after it.
 	peekToken lexer.Token
+	peekSpan  diag.Span
+	peeked    bool
+
+	// peekNewline is set if there may be a line break before peekToken.
+	peekNewline bool
+
+	// advance reports whether Next must move past the current token before
+	// parsing the next statement.
+	advance bool
 
 	prefixParseFns map[lexer.TokenType]prefixParseFn
 	infixParseFns  map[lexer.TokenType]infixParseFn
@@ -24,27 +66,53 @@ type Parser struct {
 
 const (
 	Lowest int = iota + 1
+	Assignment
+	LogicalOr
+	LogicalAnd
 	Equals
 	LessGreater
+	BitwiseOr
+	BitwiseXor
+	BitwiseAnd
+	Shift
 	Sum
 	Product
 	Prefix
+	Exponent
 	Call
+	Index
 )
 
 var precedences = map[lexer.TokenType]int{
-	lexer.Equal:        Equals,
-	lexer.NotEqual:     Equals,
-	lexer.LessThan:     LessGreater,
-	lexer.GreaterThan:  LessGreater,
-	lexer.Plus:         Sum,
-	lexer.Minus:        Sum,
-	lexer.ForwardSlash: Product,
-	lexer.Asterisk:     Product,
-	lexer.LParen:       Call,
-}
-
-func New(l *lexer.Lexer) *Parser {
+	lexer.Assign:             Assignment,
+	lexer.PlusAssign:         Assignment,
+	lexer.MinusAssign:        Assignment,
+	lexer.AsteriskAssign:     Assignment,
+	lexer.ForwardSlashAssign: Assignment,
+	lexer.Or:                 LogicalOr,
+	lexer.And:                LogicalAnd,
+	lexer.Equal:              Equals,
+	lexer.NotEqual:           Equals,
+	lexer.LessThan:           LessGreater,
+	lexer.GreaterThan:        LessGreater,
+	lexer.LessEqual:          LessGreater,
+	lexer.GreaterEqual:       LessGreater,
+	lexer.Pipe:               BitwiseOr,
+	lexer.Caret:              BitwiseXor,
+	lexer.Ampersand:          BitwiseAnd,
+	lexer.ShiftLeft:          Shift,
+	lexer.ShiftRight:         Shift,
+	lexer.Plus:               Sum,
+	lexer.Minus:              Sum,
+	lexer.ForwardSlash:       Product,
+	lexer.Asterisk:           Product,
+	lexer.Percent:            Product,
+	lexer.Power:              Exponent,
+	lexer.LParen:             Call,
+	lexer.LBracket:           Index,
+}
+
+func New(l TokenSource) *Parser {
 	p := &Parser{
 		l:      l,
 		Errors: []string{},
@@ -53,13 +121,18 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[lexer.TokenType]prefixParseFn)
 	p.registerPrefix(lexer.Ident, p.parseIdentifier)
 	p.registerPrefix(lexer.Int, p.parseIntegerLiteral)
+	p.registerPrefix(lexer.Float, p.parseFloatLiteral)
+	p.registerPrefix(lexer.String, p.parseStringLiteral)
+	p.registerPrefix(lexer.TemplateHead, p.parseTemplateLiteral)
 	p.registerPrefix(lexer.Bang, p.parsePrefixExpression)
 	p.registerPrefix(lexer.Minus, p.parsePrefixExpression)
+	p.registerPrefix(lexer.Tilde, p.parsePrefixExpression)
 	p.registerPrefix(lexer.True, p.parseBoolean)
 	p.registerPrefix(lexer.False, p.parseBoolean)
 	p.registerPrefix(lexer.LParen, p.parseGroupedExpression)
 	p.registerPrefix(lexer.If, p.parseIfExpression)
 	p.registerPrefix(lexer.Function, p.parseFunctionLiteral)
+	p.registerPrefix(lexer.LBracket, p.parseArrayLiteral)
 
 	p.infixParseFns = make(map[lexer.TokenType]infixParseFn)
 	p.registerInfix(lexer.Plus, p.parseInfixExpression)
@@ -70,31 +143,140 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(lexer.NotEqual, p.parseInfixExpression)
 	p.registerInfix(lexer.LessThan, p.parseInfixExpression)
 	p.registerInfix(lexer.GreaterThan, p.parseInfixExpression)
+	p.registerInfix(lexer.LessEqual, p.parseInfixExpression)
+	p.registerInfix(lexer.GreaterEqual, p.parseInfixExpression)
+	p.registerInfix(lexer.And, p.parseInfixExpression)
+	p.registerInfix(lexer.Or, p.parseInfixExpression)
+	p.registerInfix(lexer.Percent, p.parseInfixExpression)
+	p.registerInfix(lexer.Power, p.parseInfixExpression)
+	p.registerInfix(lexer.Ampersand, p.parseInfixExpression)
+	p.registerInfix(lexer.Pipe, p.parseInfixExpression)
+	p.registerInfix(lexer.Caret, p.parseInfixExpression)
+	p.registerInfix(lexer.ShiftLeft, p.parseInfixExpression)
+	p.registerInfix(lexer.ShiftRight, p.parseInfixExpression)
 	p.registerInfix(lexer.LParen, p.parseCallExpression)
+	p.registerInfix(lexer.LBracket, p.parseIndexExpression)
+	p.registerInfix(lexer.Assign, p.parseAssignExpression)
+	p.registerInfix(lexer.PlusAssign, p.parseAssignExpression)
+	p.registerInfix(lexer.MinusAssign, p.parseAssignExpression)
+	p.registerInfix(lexer.AsteriskAssign, p.parseAssignExpression)
+	p.registerInfix(lexer.ForwardSlashAssign, p.parseAssignExpression)
 
-	p.nextToken()
-	p.nextToken()
+	// The first token is read by the first call to Next.
+	p.advance = true
 
 	return p
 }
 
-func (p *Parser) peekError(t lexer.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+// errorAt records an error found at span.
+func (p *Parser) errorAt(span diag.Span, msg string, notes ...string) {
 	p.Errors = append(p.Errors, msg)
+	p.Diagnostics = append(p.Diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Span:     span,
+		Message:  msg,
+		Notes:    notes,
+	})
+}
+
+func (p *Parser) peekError(t lexer.TokenType) {
+	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peek().Type)
+	p.errorAt(p.peekSpan, msg, illegalNote(p.peekToken)...)
 }
 
 func (p *Parser) noPrefixParseFnError(t lexer.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.Errors = append(p.Errors, msg)
+	var notes []string
+	switch t {
+	case lexer.Eof:
+		notes = []string{"the input ends before the expression does"}
+	case lexer.Illegal:
+		notes = illegalNote(p.curToken)
+	}
+	p.errorAt(p.curSpan, msg, notes...)
+}
+
+// illegalNote explains why tok is illegal, if it is.
+func illegalNote(tok lexer.Token) []string {
+	switch {
+	case tok.Type != lexer.Illegal:
+		return nil
+	case strings.HasPrefix(tok.Literal, `"`) || strings.HasPrefix(tok.Literal, "}"):
+		return []string{"the string is not terminated"}
+	default:
+		return []string{fmt.Sprintf("%q is not part of any token", tok.Literal)}
+	}
+}
+
+// keywords are the words a name is compared with to see if it is a
+// misspelled keyword.
+var keywords = lexer.Keywords()
+
+// checkKeyword warns about expr, which starts at span, if it is a name that
+// is probably a misspelled keyword: a name followed on the same line by
+// more of the statement, which cannot continue the expression, as in
+// "retrun x", or a call of one followed by a block, as in "fnc(x) { x }".
+// Only the second is looked for if name is false.
+func (p *Parser) checkKeyword(expr ast.Expression, span diag.Span, name bool) {
+	if p.peek(); p.peekNewline {
+		return
+	}
+
+	var ident *ast.Identifier
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		switch p.peek().Type {
+		case lexer.Semicolon, lexer.RSquirly, lexer.Eof:
+			return
+		}
+		if name {
+			ident = expr
+		}
+	case *ast.CallExpression:
+		if p.peekTokenIs(lexer.LSquirly) {
+			ident, _ = expr.Function.(*ast.Identifier)
+		}
+	}
+	if ident == nil {
+		return
+	}
+
+	keyword, close := diag.Closest(ident.Value, keywords)
+	if !close {
+		return
+	}
+	p.Diagnostics = append(p.Diagnostics, diag.Diagnostic{
+		Severity:   diag.Warning,
+		Span:       span,
+		Message:    fmt.Sprintf("%s is not a keyword", ident.Value),
+		Suggestion: keyword,
+	})
 }
 
 func (p *Parser) nextToken() {
-	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.curToken, p.curSpan = p.peek(), p.peekSpan
+	p.peeked = false
+}
+
+func (p *Parser) peek() lexer.Token {
+	if !p.peeked {
+		p.peekToken = p.l.NextToken()
+		p.peekSpan = diag.NoSpan
+		if s, ok := p.l.(spanner); ok {
+			p.peekSpan.Start, p.peekSpan.End = s.Span()
+		}
+		// Without knowing, the next token could be on another line.
+		p.peekNewline = true
+		if n, ok := p.l.(newliner); ok {
+			p.peekNewline = n.NewlineBefore()
+		}
+		p.peeked = true
+	}
+	return p.peekToken
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+	if p, ok := precedences[p.peek().Type]; ok {
 		return p
 	}
 
@@ -113,21 +295,48 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
-	for p.curToken.Type != lexer.Eof {
-		stmt := p.parseStatement()
+	for {
+		stmt, ok := p.Next()
+		if !ok {
+			break
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
-		p.nextToken()
 	}
 
 	return program
 }
 
+// Next parses the next top-level statement. A statement that ends with a
+// semicolon is returned as soon as the semicolon is read, so when the tokens
+// arrive incrementally it can be handled before any more have arrived.
+// Without the semicolon, Next must read the token after the statement to see
+// that the statement does not go on. Next returns false at the end of the
+// input. A statement with syntax errors may be nil or incomplete;
+// its errors are appended to Errors.
+func (p *Parser) Next() (ast.Statement, bool) {
+	if p.advance {
+		p.nextToken()
+	}
+	if p.curToken.Type == lexer.Eof {
+		p.advance = false
+		return nil, false
+	}
+
+	p.advance = true
+	return p.parseStatement(), true
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case lexer.Let:
-		return p.parseLetStatement()
+		// A failed let statement is returned as a nil interface rather than a
+		// nil *ast.LetStatement, which callers could not tell from a statement.
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case lexer.Return:
 		return p.parseReturnStatement()
 	default:
@@ -142,7 +351,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Span: p.curSpan}
 
 	if !p.expectPeek(lexer.Assign) {
 		return nil
@@ -150,7 +359,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	p.nextToken()
 
+	span := p.curSpan
 	stmt.Value = p.parseExpression(Lowest)
+	p.checkKeyword(stmt.Value, span, false)
 
 	if p.peekTokenIs(lexer.Semicolon) {
 		p.nextToken()
@@ -164,7 +375,9 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	p.nextToken()
 
+	span := p.curSpan
 	stmt.ReturnValue = p.parseExpression(Lowest)
+	p.checkKeyword(stmt.ReturnValue, span, false)
 
 	if p.peekTokenIs(lexer.Semicolon) {
 		p.nextToken()
@@ -176,7 +389,9 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
+	span := p.curSpan
 	stmt.Expression = p.parseExpression(Lowest)
+	p.checkKeyword(stmt.Expression, span, true)
 
 	if p.peekTokenIs(lexer.Semicolon) {
 		p.nextToken()
@@ -194,7 +409,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	leftExp := prefix()
 
 	for !p.peekTokenIs(lexer.Semicolon) && precedence < p.peekPrecedence() {
-		infix := p.infixParseFns[p.peekToken.Type]
+		infix := p.infixParseFns[p.peek().Type]
 		if infix == nil {
 			return leftExp
 		}
@@ -208,16 +423,44 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Span: p.curSpan}
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
+	// ParseInt would take 010 to be octal, as C does, but octal is only
+	// written with 0o.
+	if literal := p.curToken.Literal; len(literal) > 1 && literal[0] == '0' && strings.IndexByte("0123456789_", literal[1]) >= 0 {
+		msg := fmt.Sprintf("could not parse %q as integer", literal)
+		p.errorAt(p.curSpan, msg, "a decimal integer cannot start with 0; octal is written with 0o")
+		return nil
+	}
+
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: value}
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.Errors = append(p.Errors, msg)
+		p.errorAt(p.curSpan, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.errorAt(p.curSpan, msg)
 		return nil
 	}
 
@@ -226,6 +469,35 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	template := &ast.TemplateLiteral{
+		Token:   p.curToken,
+		Strings: []string{p.curToken.Literal},
+	}
+
+	for {
+		p.nextToken()
+		template.Expressions = append(template.Expressions, p.parseExpression(Lowest))
+
+		if !p.peekTokenIs(lexer.TemplateMiddle) {
+			break
+		}
+		p.nextToken()
+		template.Strings = append(template.Strings, p.curToken.Literal)
+	}
+
+	if !p.expectPeek(lexer.TemplateTail) {
+		return nil
+	}
+	template.Strings = append(template.Strings, p.curToken.Literal)
+
+	return template
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	b := &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(lexer.True)}
 	return b
@@ -252,6 +524,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+	if p.curTokenIs(lexer.Power) {
+		// Exponentiation is right associative: a ** b ** c is a ** (b ** c).
+		precedence--
+	}
 	p.nextToken()
 
 	expression.Right = p.parseExpression(precedence)
@@ -265,7 +541,6 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	exp := p.parseExpression(Lowest)
 
 	if !p.expectPeek(lexer.RParen) {
-		p.peekError(lexer.RParen)
 		return nil
 	}
 
@@ -350,13 +625,13 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Span: p.curSpan}
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(lexer.Comma) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Span: p.curSpan}
 		identifiers = append(identifiers, ident)
 	}
 
@@ -369,32 +644,73 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(lexer.RParen)
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(lexer.RBracket)
+	return array
+}
+
+func (p *Parser) parseExpressionList(end lexer.TokenType) []ast.Expression {
+	list := []ast.Expression{}
 
-	if p.peekTokenIs(lexer.RParen) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(Lowest))
+	list = append(list, p.parseExpression(Lowest))
 
 	for p.peekTokenIs(lexer.Comma) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(Lowest))
+		list = append(list, p.parseExpression(Lowest))
 	}
 
-	if !p.expectPeek(lexer.RParen) {
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(Lowest)
+
+	if !p.expectPeek(lexer.RBracket) {
 		return nil
 	}
 
-	return args
+	return exp
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		msg := fmt.Sprintf("invalid assignment target for %s", p.curToken.Literal)
+		p.errorAt(p.curSpan, msg, "only names and index expressions can be assigned to")
+		return nil
+	}
+
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	// Assignment is right associative, so a = b = c parses as a = (b = c).
+	p.nextToken()
+	expression.Value = p.parseExpression(Assignment - 1)
+
+	return expression
 }
 
 func (p *Parser) curTokenIs(t lexer.TokenType) bool {
@@ -402,7 +718,7 @@ func (p *Parser) curTokenIs(t lexer.TokenType) bool {
 }
 
 func (p *Parser) peekTokenIs(t lexer.TokenType) bool {
-	return p.peekToken.Type == t
+	return p.peek().Type == t
 }
 
 func (p *Parser) expectPeek(t lexer.TokenType) bool {
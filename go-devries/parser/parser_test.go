@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"monkey/ast"
+	"monkey/diag"
 	"monkey/lexer"
 	"testing"
 )
@@ -144,6 +145,90 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestNumericLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"0x1F;", int64(31)},
+		{"0o17;", int64(15)},
+		{"0;", int64(0)},
+		{"0b101;", int64(5)},
+		{"1_000;", int64(1000)},
+		{"3.25;", 3.25},
+		{"1_0.5e1;", 105.0},
+		{"2E-1;", 0.2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch expected := tt.expected.(type) {
+		case int64:
+			lit, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+			}
+			if lit.Value != expected {
+				t.Errorf("lit.Value not %d. got=%d", expected, lit.Value)
+			}
+		case float64:
+			lit, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+			}
+			if lit.Value != expected {
+				t.Errorf("lit.Value not %g. got=%g", expected, lit.Value)
+			}
+		}
+	}
+}
+
+func TestBigIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"99999999999999999999;", "99999999999999999999"},
+		{"9223372036854775808;", "9223372036854775808"},
+		{"0x1_0000_0000_0000_0000;", "18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		lit, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BigIntegerLiteral. got=%T", stmt.Expression)
+		}
+		if lit.Value.String() != tt.expected {
+			t.Errorf("lit.Value not %s. got=%s", tt.expected, lit.Value)
+		}
+	}
+}
+
+func TestMalformedNumericLiterals(t *testing.T) {
+	tests := []string{"0x;", "0b102;", "1__0;", "1_;", "08;", "09;", "010;", "0_1;", "00;", "0123456789012345678901;"}
+
+	for _, input := range tests {
+		l := lexer.NewLexer(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
+
 func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	literal, ok := il.(*ast.IntegerLiteral)
 	if !ok {
@@ -206,6 +291,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"~15;", "~", 15},
 		{"!true", "!", true},
 		{"!false", "!", false},
 	}
@@ -253,6 +339,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 	}
@@ -379,6 +476,47 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"x = y = 5",
+			"(x = (y = 5))",
+		},
+		{
+			"x += 1 + 2 * 3",
+			"(x += (1 + (2 * 3)))",
+		},
+		{
+			"a[i + 1] *= b == c",
+			"((a[(i + 1)]) *= (b == c))",
+		},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"a < b || c", "((a < b) || c)"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b | c & d", "((a & b) | (c & d))"},
+		{"a & b == c", "((a & b) == c)"},
+		{"a << b + c", "(a << (b + c))"},
+		{"a << b >> c", "((a << b) >> c)"},
+		{"a & b << c", "(a & (b << c))"},
+		{"a < b << c", "(a < (b << c))"},
+		{"~a & b", "((~a) & b)"},
+		{"~a ** b", "(~(a ** b))"},
+		{"x = a || b", "(x = (a || b))"},
 	}
 
 	for _, tt := range tests {
@@ -756,3 +894,437 @@ func TestFunctionParameterParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.NewLexer(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.NewLexer(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    any
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += 5;", "x", "+=", 5},
+		{"x -= y;", "x", "-=", "y"},
+		{"x *= true;", "x", "*=", true},
+		{"x /= 2;", "x", "/=", 2},
+		{"arr[0] = 1;", "(arr[0])", "=", 1},
+		{"arr[i] += 1;", "(arr[i])", "+=", 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Target.String() != tt.target {
+			t.Errorf("exp.Target is not %q. got=%q", tt.target, exp.Target.String())
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.operator, exp.Operator)
+		}
+		testLiteralExpression(t, exp.Value, tt.value)
+	}
+}
+
+func TestInvalidAssignmentTargets(t *testing.T) {
+	tests := []string{
+		"5 = 1;",
+		"f() = 1;",
+		"(a + b) += 1;",
+		"[1, 2] = 3;",
+		"a && b = c;",
+	}
+
+	for _, input := range tests {
+		l := lexer.NewLexer(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
+
+func TestUnclosedGroupedExpression(t *testing.T) {
+	l := lexer.NewLexer("(1 + 2;")
+	p := New(l)
+	p.ParseProgram()
+
+	expected := "expected next token to be RParen, got Semicolon instead"
+	if len(p.Errors) != 1 || p.Errors[0] != expected {
+		t.Errorf("expected the single error %q, got %q", expected, p.Errors)
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.NewLexer(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestTemplateLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input               string
+		expectedStrings     []string
+		expectedExpressions []string
+		expectedString      string
+	}{
+		{
+			input:               `"hello ${name}";`,
+			expectedStrings:     []string{"hello ", ""},
+			expectedExpressions: []string{"name"},
+			expectedString:      `"hello ${name}"`,
+		},
+		{
+			input:               `"hello ${users[0]}, you have ${len(items)} items"`,
+			expectedStrings:     []string{"hello ", ", you have ", " items"},
+			expectedExpressions: []string{"(users[0])", "len(items)"},
+			expectedString:      `"hello ${(users[0])}, you have ${len(items)} items"`,
+		},
+		{
+			input:               `"${a + b * c}"`,
+			expectedStrings:     []string{"", ""},
+			expectedExpressions: []string{"(a + (b * c))"},
+			expectedString:      `"${(a + (b * c))}"`,
+		},
+		{
+			input:               `"say \"${"hi ${x}"}\"\n"`,
+			expectedStrings:     []string{`say "`, "\"\n"},
+			expectedExpressions: []string{`"hi ${x}"`},
+			expectedString:      `"say \"${"hi ${x}"}\"\n"`,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		template, ok := stmt.Expression.(*ast.TemplateLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.TemplateLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(template.Strings) != len(tt.expectedStrings) {
+			t.Fatalf("wrong number of strings. want=%d, got=%d", len(tt.expectedStrings), len(template.Strings))
+		}
+		for i, s := range tt.expectedStrings {
+			if template.Strings[i] != s {
+				t.Errorf("string %d wrong. want=%q, got=%q", i, s, template.Strings[i])
+			}
+		}
+
+		if len(template.Expressions) != len(tt.expectedExpressions) {
+			t.Fatalf("wrong number of expressions. want=%d, got=%d", len(tt.expectedExpressions), len(template.Expressions))
+		}
+		for i, e := range tt.expectedExpressions {
+			if template.Expressions[i].String() != e {
+				t.Errorf("expression %d wrong. want=%q, got=%q", i, e, template.Expressions[i].String())
+			}
+		}
+
+		if template.String() != tt.expectedString {
+			t.Errorf("template.String() wrong. want=%q, got=%q", tt.expectedString, template.String())
+		}
+	}
+}
+
+func TestMalformedTemplateLiterals(t *testing.T) {
+	tests := []string{
+		`"${}"`,
+		`"${a b}"`,
+		`"${a"`,
+		`"abc`,
+	}
+
+	for _, input := range tests {
+		l := lexer.NewLexer(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
+
+func TestParsingFromReplay(t *testing.T) {
+	input := `let add = fn(x, y) { x + y; }; add(1, 2 * 3);`
+
+	l := lexer.NewLexer(input)
+	var tokens []lexer.Token
+	for tok := l.NextToken(); tok.Type != lexer.Eof; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	expected := New(lexer.NewLexer(input)).ParseProgram()
+
+	p := New(lexer.NewReplay(tokens))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != expected.String() {
+		t.Errorf("program wrong. expected=%q, got=%q", expected.String(), program.String())
+	}
+}
+
+// countingSource counts the tokens read from it.
+type countingSource struct {
+	TokenSource
+	reads int
+}
+
+func (s *countingSource) NextToken() lexer.Token {
+	s.reads++
+	return s.TokenSource.NextToken()
+}
+
+func TestNextReadsNoFurtherThanStatement(t *testing.T) {
+	source := &countingSource{TokenSource: lexer.NewLexer("let x = 5; x * 2; fn(a) { a }")}
+	p := New(source)
+	if source.reads != 0 {
+		t.Fatalf("New read %d tokens, want 0", source.reads)
+	}
+
+	tests := []struct {
+		expected string
+		reads    int
+	}{
+		{"let x = 5;", 5},
+		{"(x * 2)", 9},
+		// Without a semicolon the expression only ends at the Eof.
+		{"fn(a) a", 17},
+	}
+
+	for _, tt := range tests {
+		stmt, ok := p.Next()
+		checkParserErrors(t, p)
+		if !ok {
+			t.Fatalf("Next returned false, want statement %q", tt.expected)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("statement wrong. expected=%q, got=%q", tt.expected, stmt.String())
+		}
+		if source.reads != tt.reads {
+			t.Errorf("read %d tokens after %q, want %d", source.reads, tt.expected, tt.reads)
+		}
+	}
+
+	if stmt, ok := p.Next(); ok {
+		t.Errorf("Next returned %v at the end of the input", stmt)
+	}
+	if stmt, ok := p.Next(); ok {
+		t.Errorf("Next returned %v after the end of the input", stmt)
+	}
+}
+
+func TestSExpr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = a + b * 2;", "(let x (+ a (* b 2)))"},
+		{"return -x;", "(return (- x))"},
+		{"!true == false", "(== (! true) false)"},
+		{"if (x < y) { x } else { y; z }", "(if (< x y) (block x) (block y z))"},
+		{"if (x) {}", "(if x (block))"},
+		{"fn(x, y) { return x; }(1, 2.5)", "(call (fn (x y) (block (return x))) 1 2.5)"},
+		{"a[0] += [1, \"two\"]", "(+= (index a 0) (array 1 \"two\"))"},
+		{"\"hi ${name}!\"", "(template \"hi \" name \"!\")"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"let x = 1; x", "(let x 1)\nx"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := ast.SExpr(program); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	p := New(lexer.NewLexer("let x = ;"))
+	if got := ast.SExpr(p.ParseProgram()); got != "(let x <nil>)" {
+		t.Errorf("expected <nil> for the missing value, got=%q", got)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input string
+		start int
+		end   int
+		notes int
+	}{
+		{"let x = (1 + 2", 14, 14, 0},
+		{"let = 5;", 4, 5, 0},
+		{"let x = 1 +\n", 12, 12, 1},
+		{"1 = 2", 2, 3, 1},
+		{"let s = \"abc", 8, 12, 1},
+		{"let x = 1 @ 2", 10, 11, 1},
+		{"f(1, 2 3)", 7, 8, 0},
+		{"0x", 0, 2, 0},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+		if len(p.Diagnostics) == 0 || len(p.Diagnostics) != len(p.Errors) {
+			t.Errorf("%q: expected a diagnostic for each of %q, got %d", tt.input, p.Errors, len(p.Diagnostics))
+			continue
+		}
+
+		d := p.Diagnostics[0]
+		if d.Message != p.Errors[0] {
+			t.Errorf("%q: expected message %q, got %q", tt.input, p.Errors[0], d.Message)
+		}
+		if d.Span.Start != tt.start || d.Span.End != tt.end || len(d.Notes) != tt.notes {
+			t.Errorf("%q: expected span %d-%d with %d notes, got %d-%d with %q",
+				tt.input, tt.start, tt.end, tt.notes, d.Span.Start, d.Span.End, d.Notes)
+		}
+	}
+}
+
+func TestDiagnosticsWithoutSpans(t *testing.T) {
+	p := New(lexer.NewReplay([]lexer.Token{{Type: lexer.Let, Literal: "let"}}))
+	p.ParseProgram()
+	if len(p.Diagnostics) != 1 || p.Diagnostics[0].Span.Valid() {
+		t.Errorf("expected one diagnostic without a span, got %+v", p.Diagnostics)
+	}
+}
+
+func TestMisspelledKeywords(t *testing.T) {
+	tests := []struct {
+		input      string
+		suggestion string
+		start      int
+	}{
+		{"retrun x;", "return", 0},
+		{"let f = fnc(x) { x };", "fn", 8},
+		{"return fnn(x) { x }", "fn", 7},
+		{"iff (x) { 1 }", "if", 0},
+		{"if (x) { 1 } elsee { 2 }", "else", 13},
+		{"let f = function(x) { x };", "", 0},
+		{"var x = 1;", "", 0},
+		{"retrun\nx;", "", 0},
+		{"let fun = 1\nfun\nputs(fun)", "", 0},
+		{"let f = fnc(x)\n{ x };", "", 0},
+		{"retrun;", "", 0},
+		{"let x = retrun;", "", 0},
+		{"foo(x) { 1 }", "", 0},
+		{"if (x) { retrun }", "", 0},
+		{"x y", "", 0},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+
+		var warnings []diag.Diagnostic
+		for _, d := range p.Diagnostics {
+			if d.Severity == diag.Warning {
+				warnings = append(warnings, d)
+			}
+		}
+		if tt.suggestion == "" {
+			if len(warnings) != 0 {
+				t.Errorf("%q: expected no warnings, got %+v", tt.input, warnings)
+			}
+			continue
+		}
+		if len(warnings) != 1 || warnings[0].Suggestion != tt.suggestion || warnings[0].Span.Start != tt.start {
+			t.Errorf("%q: expected a suggestion of %q at %d, got %+v", tt.input, tt.suggestion, tt.start, warnings)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package printer formats an AST as Monkey source code. Unlike the String
+// methods of the ast package, which are meant for debugging, the output
+// parses back to the same tree: statements are terminated, blocks keep
+// their braces and parentheses are added wherever precedence needs them.
+package printer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"monkey/ast"
+	"monkey/parser"
+)
+
+// Fprint writes node to w as Monkey source. A program or block is written
+// one statement per line and indented with tabs.
+func Fprint(w io.Writer, node ast.Node) error {
+	bw := bufio.NewWriter(w)
+	p := &printer{out: bw}
+	p.node(node)
+	return bw.Flush()
+}
+
+// String returns node as Monkey source, as written by Fprint.
+func String(node ast.Node) string {
+	var out strings.Builder
+	Fprint(&out, node)
+	return out.String()
+}
+
+type printer struct {
+	out    *bufio.Writer
+	indent int
+}
+
+// precedences gives the binding strength of each infix operator, using the
+// parser's levels.
+var precedences = map[string]int{
+	"||": parser.LogicalOr,
+	"&&": parser.LogicalAnd,
+	"==": parser.Equals,
+	"!=": parser.Equals,
+	"<":  parser.LessGreater,
+	">":  parser.LessGreater,
+	"<=": parser.LessGreater,
+	">=": parser.LessGreater,
+	"|":  parser.BitwiseOr,
+	"^":  parser.BitwiseXor,
+	"&":  parser.BitwiseAnd,
+	"<<": parser.Shift,
+	">>": parser.Shift,
+	"+":  parser.Sum,
+	"-":  parser.Sum,
+	"*":  parser.Product,
+	"/":  parser.Product,
+	"%":  parser.Product,
+	"**": parser.Exponent,
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.write("\n" + strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) node(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for i, s := range node.Statements {
+			if i > 0 {
+				p.newline()
+			}
+			p.statement(s)
+		}
+		if len(node.Statements) > 0 {
+			p.write("\n")
+		}
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expression(node, parser.Lowest)
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value + " = ")
+		p.expression(stmt.Value, parser.Lowest)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue, parser.Lowest)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		// The semicolon keeps the next statement from being read as a
+		// continuation of this one, as in a call or an index.
+		p.expression(stmt.Expression, parser.Lowest)
+		p.write(";")
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if block == nil || len(block.Statements) == 0 {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	for _, s := range block.Statements {
+		p.newline()
+		p.statement(s)
+	}
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+// expression writes expr, in parentheses if it binds less tightly than
+// precedence requires.
+func (p *printer) expression(expr ast.Expression, precedence int) {
+	if expr == nil {
+		return
+	}
+
+	if precedenceOf(expr) < precedence {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		p.write(expr.Value)
+	case *ast.IntegerLiteral:
+		p.write(expr.Token.Literal)
+	case *ast.BigIntegerLiteral:
+		p.write(expr.Token.Literal)
+	case *ast.FloatLiteral:
+		p.write(expr.Token.Literal)
+	case *ast.Boolean:
+		p.write(expr.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(`"` + ast.EscapeString(expr.Value) + `"`)
+	case *ast.TemplateLiteral:
+		p.write(`"`)
+		for i, s := range expr.Strings {
+			p.write(ast.EscapeString(s))
+			if i < len(expr.Expressions) {
+				p.write("${")
+				p.expression(expr.Expressions[i], parser.Lowest)
+				p.write("}")
+			}
+		}
+		p.write(`"`)
+	case *ast.PrefixExpression:
+		p.write(expr.Operator)
+		p.expression(expr.Right, parser.Prefix)
+	case *ast.InfixExpression:
+		// Operands of equal precedence only need parentheses on the side
+		// the operator does not associate to.
+		left, right := precedences[expr.Operator], precedences[expr.Operator]+1
+		if expr.Operator == "**" {
+			left, right = right, left
+		}
+		p.expression(expr.Left, left)
+		p.write(" " + expr.Operator + " ")
+		p.expression(expr.Right, right)
+	case *ast.AssignExpression:
+		p.expression(expr.Target, parser.Assignment+1)
+		p.write(" " + expr.Operator + " ")
+		p.expression(expr.Value, parser.Assignment)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(expr.Condition, parser.Lowest)
+		p.write(") ")
+		p.block(expr.Consequence)
+		if expr.Alternative != nil {
+			p.write(" else ")
+			p.block(expr.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		params := make([]string, len(expr.Parameters))
+		for i, param := range expr.Parameters {
+			params[i] = param.Value
+		}
+		p.write("fn(" + strings.Join(params, ", ") + ") ")
+		p.block(expr.Body)
+	case *ast.CallExpression:
+		p.expression(expr.Function, parser.Call)
+		p.write("(")
+		p.list(expr.Arguments)
+		p.write(")")
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.list(expr.Elements)
+		p.write("]")
+	case *ast.IndexExpression:
+		p.expression(expr.Left, parser.Call)
+		p.write("[")
+		p.expression(expr.Index, parser.Lowest)
+		p.write("]")
+	}
+}
+
+func (p *printer) list(exprs []ast.Expression) {
+	for i, e := range exprs {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(e, parser.Lowest)
+	}
+}
+
+// precedenceOf returns how tightly expr binds: the precedence of its
+// operator, or one above every operator for literals, names and other
+// expressions that are never split by their surroundings.
+func precedenceOf(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.PrefixExpression:
+		return parser.Prefix
+	case *ast.InfixExpression:
+		return precedences[expr.Operator]
+	case *ast.AssignExpression:
+		return parser.Assignment
+	case *ast.CallExpression:
+		return parser.Call
+	case *ast.IndexExpression:
+		return parser.Index
+	default:
+		return parser.Index + 1
+	}
+}
@@ -0,0 +1,105 @@
+package printer
+
+import (
+	"math/rand"
+	"testing"
+
+	"monkey/ast"
+	"monkey/astgen"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5", "let x = 5;\n"},
+		{"a + b * c", "a + b * c;\n"},
+		{"(a + b) * c", "(a + b) * c;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"(a - b) - c", "a - b - c;\n"},
+		{"2 ** 3 ** 2", "2 ** 3 ** 2;\n"},
+		{"(2 ** 3) ** 2", "(2 ** 3) ** 2;\n"},
+		{"-a ** b", "-a ** b;\n"},
+		{"(-a) ** b", "(-a) ** b;\n"},
+		{"- -a", "--a;\n"},
+		{"a = b = c", "a = b = c;\n"},
+		{"(a = b) + 1", "(a = b) + 1;\n"},
+		{"f(a)(b)[0]", "f(a)(b)[0];\n"},
+		{"(a + b)[0]", "(a + b)[0];\n"},
+		{"x; (y)", "x;\ny;\n"},
+		{"0x1F + 1_000 + 1.5e3", "0x1F + 1_000 + 1.5e3;\n"},
+		{`"a\"b" + "x${y + "\n"}z"`, `"a\"b" + "x${y + "\n"}z";` + "\n"},
+		{"if (x) {}", "if (x) {};\n"},
+		{
+			"if (x < y) { x } else { let z = y; z }",
+			"if (x < y) {\n\tx;\n} else {\n\tlet z = y;\n\tz;\n};\n",
+		},
+		{
+			"let f = fn(a, b) { fn() { a + b } };",
+			"let f = fn(a, b) {\n\tfn() {\n\t\ta + b;\n\t};\n};\n",
+		},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors) > 0 {
+			t.Fatalf("%q: parser errors: %v", tt.input, p.Errors)
+		}
+
+		if got := String(program); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestGeneratedPrograms checks that random programs print as source that
+// parses back to the same tree.
+func TestGeneratedPrograms(t *testing.T) {
+	g := astgen.New(rand.New(rand.NewSource(1)), astgen.Full())
+
+	for i := 0; i < 2000; i++ {
+		program := g.Program()
+		printed := String(program)
+
+		p := parser.New(lexer.NewLexer(printed))
+		reparsed := p.ParseProgram()
+		if len(p.Errors) > 0 {
+			t.Fatalf("printed program does not parse: %v\n%s", p.Errors, printed)
+		}
+		if want, got := ast.SExpr(program), ast.SExpr(reparsed); got != want {
+			t.Fatalf("printed program parses differently:\n%s\nwant:\n%s\ngot:\n%s", printed, want, got)
+		}
+	}
+}
+
+// FuzzPrinter checks that the printed form of every program that parses
+// without errors parses back to the same tree, and prints the same again.
+// The seed corpus in testdata/fuzz comes from the inputs of the other tests.
+func FuzzPrinter(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.NewLexer(input))
+		program := p.ParseProgram()
+		if len(p.Errors) > 0 {
+			return
+		}
+
+		printed := String(program)
+		reparser := parser.New(lexer.NewLexer(printed))
+		reparsed := reparser.ParseProgram()
+		if len(reparser.Errors) > 0 {
+			t.Fatalf("printed program does not parse: %v\n%s", reparser.Errors, printed)
+		}
+
+		if want, got := ast.SExpr(program), ast.SExpr(reparsed); got != want {
+			t.Fatalf("printed program parses differently:\n%s\nwant:\n%s\ngot:\n%s", printed, want, got)
+		}
+		if again := String(reparsed); again != printed {
+			t.Fatalf("printing is not stable:\n%s\nthen:\n%s", printed, again)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package reduce
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/printer"
+)
+
+// reduceAST tries each edit of the parsed program in turn, keeping those
+// that leave it interesting. Edits are numbered in the order the editor
+// walks the tree, and each candidate is made by parsing the current source
+// afresh and applying one edit to it. The result is printed by the printer
+// package, unless source does not parse or neither an edit nor printing
+// made it smaller.
+func reduceAST(source string, interesting Predicate) string {
+	p := parser.New(lexer.NewLexer(source))
+	program := p.ParseProgram()
+	if len(p.Errors) > 0 {
+		return source
+	}
+
+	// Printing alone drops redundant parentheses, which the token pass can
+	// only remove one at a time.
+	if printed := printer.String(program); len(printed) < len(source) && interesting(printed) {
+		source = printed
+	}
+
+	for k := 0; ; {
+		p := parser.New(lexer.NewLexer(source))
+		program := p.ParseProgram()
+		if len(p.Errors) > 0 {
+			return source
+		}
+
+		e := &editor{target: k}
+		e.statements(&program.Statements)
+		if !e.done {
+			return source
+		}
+
+		// A kept edit renumbers the ones after it, so the next candidate is
+		// the edit with the same number in the new program.
+		if candidate := printer.String(program); candidate != source && interesting(candidate) {
+			source = candidate
+		} else {
+			k++
+		}
+	}
+}
+
+// editor walks a tree and makes the edit numbered target.
+type editor struct {
+	target int
+	count  int
+	done   bool
+}
+
+// next reports whether the edit being considered is the one to make.
+func (e *editor) next() bool {
+	if e.done {
+		return false
+	}
+	e.count++
+	e.done = e.count-1 == e.target
+	return e.done
+}
+
+func (e *editor) statements(list *[]ast.Statement) {
+	for i := range *list {
+		if e.next() {
+			*list = append((*list)[:i:i], (*list)[i+1:]...)
+			return
+		}
+	}
+	for _, stmt := range *list {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			e.expression(&stmt.Value)
+		case *ast.ReturnStatement:
+			e.expression(&stmt.ReturnValue)
+		case *ast.ExpressionStatement:
+			e.expression(&stmt.Expression)
+		}
+	}
+}
+
+func (e *editor) expressions(list *[]ast.Expression) {
+	for i := range *list {
+		if e.next() {
+			*list = append((*list)[:i:i], (*list)[i+1:]...)
+			return
+		}
+	}
+	for i := range *list {
+		e.expression(&(*list)[i])
+	}
+}
+
+// expression tries replacing the expression in slot with each of its
+// operands and then with 0, before the edits inside it, which include
+// emptying strings.
+func (e *editor) expression(slot *ast.Expression) {
+	expr := *slot
+	if expr == nil {
+		return
+	}
+
+	for _, operand := range operands(expr) {
+		if e.next() {
+			*slot = operand
+			return
+		}
+	}
+	if !isZero(expr) && e.next() {
+		*slot = &ast.IntegerLiteral{Token: lexer.Token{Type: lexer.Int, Literal: "0"}}
+		return
+	}
+
+	switch expr := expr.(type) {
+	case *ast.StringLiteral:
+		if expr.Value != "" && e.next() {
+			expr.Value = ""
+		}
+	case *ast.PrefixExpression:
+		e.expression(&expr.Right)
+	case *ast.InfixExpression:
+		e.expression(&expr.Left)
+		e.expression(&expr.Right)
+	case *ast.AssignExpression:
+		e.expression(&expr.Value)
+	case *ast.IfExpression:
+		e.expression(&expr.Condition)
+		e.statements(&expr.Consequence.Statements)
+		if expr.Alternative != nil {
+			if e.next() {
+				expr.Alternative = nil
+				return
+			}
+			e.statements(&expr.Alternative.Statements)
+		}
+	case *ast.FunctionLiteral:
+		for i := range expr.Parameters {
+			if e.next() {
+				expr.Parameters = append(expr.Parameters[:i:i], expr.Parameters[i+1:]...)
+				return
+			}
+		}
+		e.statements(&expr.Body.Statements)
+	case *ast.CallExpression:
+		e.expression(&expr.Function)
+		e.expressions(&expr.Arguments)
+	case *ast.ArrayLiteral:
+		e.expressions(&expr.Elements)
+	case *ast.IndexExpression:
+		e.expression(&expr.Left)
+		e.expression(&expr.Index)
+	case *ast.TemplateLiteral:
+		for i := range expr.Strings {
+			if expr.Strings[i] != "" && e.next() {
+				expr.Strings[i] = ""
+				return
+			}
+		}
+		for i := range expr.Expressions {
+			e.expression(&expr.Expressions[i])
+		}
+	}
+}
+
+// operands returns the subexpressions that could stand in for expr.
+func operands(expr ast.Expression) []ast.Expression {
+	switch expr := expr.(type) {
+	case *ast.PrefixExpression:
+		return []ast.Expression{expr.Right}
+	case *ast.InfixExpression:
+		return []ast.Expression{expr.Left, expr.Right}
+	case *ast.AssignExpression:
+		return []ast.Expression{expr.Target, expr.Value}
+	case *ast.IfExpression:
+		return []ast.Expression{expr.Condition}
+	case *ast.CallExpression:
+		return append([]ast.Expression{expr.Function}, expr.Arguments...)
+	case *ast.ArrayLiteral:
+		return expr.Elements
+	case *ast.IndexExpression:
+		return []ast.Expression{expr.Left, expr.Index}
+	case *ast.TemplateLiteral:
+		return expr.Expressions
+	default:
+		return nil
+	}
+}
+
+func isZero(expr ast.Expression) bool {
+	lit, ok := expr.(*ast.IntegerLiteral)
+	return ok && lit.Token.Literal == "0"
+}
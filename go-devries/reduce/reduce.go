@@ -0,0 +1,53 @@
+// Package reduce shrinks a Monkey program while a property of it still
+// holds, to turn a crashing or diverging input found by a fuzzer or a
+// differential test into a small test case.
+//
+// Reduce alternates two passes until neither makes progress. The AST pass
+// parses the program and tries, one at a time, removing statements,
+// dropping arguments, elements, parameters and else branches, emptying
+// strings, and replacing subexpressions with one of their operands or
+// with 0. The token pass removes runs of tokens with delta debugging,
+// which also works on input that does not parse.
+package reduce
+
+import (
+	"errors"
+)
+
+// Predicate reports whether a candidate program is still interesting, for
+// example whether it still crashes the parser.
+type Predicate func(source string) bool
+
+// ErrNotInteresting is returned by Reduce when the predicate does not hold
+// for the input in the first place.
+var ErrNotInteresting = errors.New("the input is not interesting")
+
+// Reduce returns a smaller program for which interesting holds, starting
+// from source. The predicate is called at most once for each candidate.
+func Reduce(source string, interesting Predicate) (string, error) {
+	interesting = cached(interesting)
+	if !interesting(source) {
+		return "", ErrNotInteresting
+	}
+
+	for {
+		before := source
+		source = reduceAST(source, interesting)
+		source = reduceTokens(source, interesting)
+		if source == before {
+			return source, nil
+		}
+	}
+}
+
+func cached(interesting Predicate) Predicate {
+	results := map[string]bool{}
+	return func(source string) bool {
+		result, ok := results[source]
+		if !ok {
+			result = interesting(source)
+			results[source] = result
+		}
+		return result
+	}
+}
@@ -0,0 +1,105 @@
+package reduce
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+const program = `let fibonacci = fn(n) {
+	if (n < 2) {
+		return n;
+	} else {
+		return fibonacci(n - 1) + fibonacci(n - 2);
+	}
+};
+let values = [1, 2, 3 * (4 + total / 2)];
+puts("total: ${len(values)}", fibonacci(10));
+`
+
+// parses returns a predicate that holds for programs without syntax errors
+// whose S-expression contains want.
+func parses(want string) Predicate {
+	return func(source string) bool {
+		p := parser.New(lexer.NewLexer(source))
+		program := p.ParseProgram()
+		return len(p.Errors) == 0 && strings.Contains(ast.SExpr(program), want)
+	}
+}
+
+// fails returns a predicate that holds for programs with a syntax error
+// that contains want.
+func fails(want string) Predicate {
+	return func(source string) bool {
+		p := parser.New(lexer.NewLexer(source))
+		p.ParseProgram()
+		for _, msg := range p.Errors {
+			if strings.Contains(msg, want) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestReduce(t *testing.T) {
+	tests := []struct {
+		source      string
+		interesting Predicate
+		expected    string
+	}{
+		{program, parses("(/ "), "0 / 0"},
+		{program, parses("(template "), `"${ 0 }"`},
+		{program, parses("(call fibonacci "), "fibonacci ( 0 )"},
+		{program + "let x = (1 + ;", fails("expected next token to be RParen"), "("},
+		{program + "let = 5;", fails("expected next token to be Ident"), "let"},
+	}
+
+	for _, tt := range tests {
+		got, err := Reduce(tt.source, tt.interesting)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.source, err)
+		}
+		if got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestReduceNotInteresting(t *testing.T) {
+	_, err := Reduce(program, parses("(**"))
+	if !errors.Is(err, ErrNotInteresting) {
+		t.Errorf("expected ErrNotInteresting, got %v", err)
+	}
+}
+
+func TestReduceTestsEachCandidateOnce(t *testing.T) {
+	seen := map[string]bool{}
+	interesting := parses("(+ ")
+	_, err := Reduce(program, func(source string) bool {
+		if seen[source] {
+			t.Fatalf("candidate tested twice: %q", source)
+		}
+		seen[source] = true
+		return interesting(source)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDDMin(t *testing.T) {
+	items := strings.Fields("a b c d e f g h i j")
+	got := ddmin(items, func(items []string) bool {
+		s := strings.Join(items, " ")
+		return strings.Contains(s, "c") && strings.Contains(s, "h")
+	})
+
+	if strings.Join(got, " ") != "c h" {
+		t.Errorf("expected [c h], got %v", got)
+	}
+}
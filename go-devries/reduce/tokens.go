@@ -0,0 +1,90 @@
+package reduce
+
+import (
+	"strings"
+
+	"monkey/ast"
+	"monkey/lexer"
+)
+
+// reduceTokens removes tokens from source with delta debugging. The result
+// has its tokens separated by single spaces.
+func reduceTokens(source string, interesting Predicate) string {
+	var tokens []string
+	l := lexer.NewLexer(source)
+	for tok, ok := l.Next(); ok && tok.Type != lexer.Eof; tok, ok = l.Next() {
+		tokens = append(tokens, tokenText(tok))
+	}
+
+	joined := strings.Join(tokens, " ")
+	if joined != source && !interesting(joined) {
+		return source
+	}
+
+	tokens = ddmin(tokens, func(tokens []string) bool {
+		return interesting(strings.Join(tokens, " "))
+	})
+	return strings.Join(tokens, " ")
+}
+
+// ddmin returns a subsequence of items for which test holds, trying the
+// complement of ever smaller chunks of it until no single item can be
+// removed. test must hold for items.
+func ddmin(items []string, test func([]string) bool) []string {
+	if len(items) == 1 && test(nil) {
+		return nil
+	}
+
+	n := 2
+	for len(items) >= 2 {
+		size := (len(items) + n - 1) / n
+		reduced := false
+
+		for start := 0; start < len(items); start += size {
+			end := start + size
+			if end > len(items) {
+				end = len(items)
+			}
+			complement := append(append([]string{}, items[:start]...), items[end:]...)
+			if test(complement) {
+				items = complement
+				if n > 2 {
+					n--
+				}
+				reduced = true
+				break
+			}
+		}
+
+		if !reduced {
+			if n >= len(items) {
+				break
+			}
+			n *= 2
+			if n > len(items) {
+				n = len(items)
+			}
+		}
+	}
+
+	if len(items) == 1 && test(nil) {
+		return nil
+	}
+	return items
+}
+
+// tokenText returns source code that lexes as tok.
+func tokenText(tok lexer.Token) string {
+	switch tok.Type {
+	case lexer.String:
+		return `"` + ast.EscapeString(tok.Literal) + `"`
+	case lexer.TemplateHead:
+		return `"` + ast.EscapeString(tok.Literal) + "${"
+	case lexer.TemplateMiddle:
+		return "}" + ast.EscapeString(tok.Literal) + "${"
+	case lexer.TemplateTail:
+		return "}" + ast.EscapeString(tok.Literal) + `"`
+	default:
+		return tok.Literal
+	}
+}
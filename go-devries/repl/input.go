@@ -0,0 +1,115 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/lineedit"
+)
+
+// lineReader reads the lines of a session.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	AddHistory(line string) error
+}
+
+// plainReader reads lines without editing, for input that is not a
+// terminal.
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *plainReader) AddHistory(string) error {
+	return nil
+}
+
+// newLineReader returns a line editor if in is a terminal, and a plain
+// reader otherwise.
+func newLineReader(in io.Reader, out io.Writer, s *session) lineReader {
+	if f, ok := in.(*os.File); ok {
+		if e, err := lineedit.Open(f, out); err == nil {
+			e.Complete = s.complete
+			s.color = os.Getenv("NO_COLOR") == ""
+			if file := historyFile(); file != "" {
+				if err := e.LoadHistory(file); err != nil {
+					fmt.Fprintf(out, "history: %v\n", err)
+				}
+			}
+			return e
+		}
+	}
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// historyFile returns the file the history is kept in: $MONKEY_HISTORY, or
+// .monkey_history in the home directory.
+func historyFile() string {
+	if file, ok := os.LookupEnv("MONKEY_HISTORY"); ok {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".monkey_history")
+}
+
+// complete returns the completions of the word before pos: commands at the
+// start of a line beginning with a colon, and otherwise keywords, builtins
+// and the names bound in the session.
+func (s *session) complete(line string, pos int) (int, []string) {
+	start := pos
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(line[:start])
+		if !lexer.IsIdentContinue(r) {
+			break
+		}
+		start -= size
+	}
+	word := line[start:pos]
+
+	var names []string
+	if strings.HasPrefix(line, ":") {
+		if start != 1 {
+			return start, nil
+		}
+		for _, cmd := range commands {
+			names = append(names, cmd.name)
+		}
+	} else {
+		names = append(names, lexer.Keywords()...)
+		names = append(names, evaluator.BuiltinNames()...)
+		names = append(names, s.env.Names()...)
+	}
+
+	var candidates []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if strings.HasPrefix(name, word) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
@@ -1,46 +1,286 @@
+// Package repl implements the interactive Monkey session. Each line is
+// evaluated in one environment that lasts for the whole session, or shown
+// according to the current mode; lines starting with a colon are commands
+// that change the mode or inspect the session:
+//
+//	:eval          evaluate each line and show its value (the default)
+//	:tokens        show the tokens of each line
+//	:ast           show the syntax tree of each line
+//	:sexpr         show the syntax tree of each line as S-expressions
+//	:env           list the names bound by evaluated lines
+//	:load file     handle the contents of file as if it were typed in
+//	:save file     write the lines evaluated without error to file
+//	:restore file  evaluate the program in file, as saved by :save
+//	:reset         forget all bindings and evaluated lines
+//	:time          toggle showing how long each line takes
+//	:help          list the commands
+//
+// On a terminal, lines can be edited, recalled from a history kept in
+// $MONKEY_HISTORY or ~/.monkey_history, and completed with tab.
 package repl
 
 import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"strings"
+	"time"
 
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/lineedit"
+	"monkey/object"
 	"monkey/parser"
+	"monkey/printer"
+	"monkey/resolver"
 )
 
 var Prompt = ">> "
 
+// mode decides how a line of input is shown.
+type mode int
+
+const (
+	evalMode mode = iota
+	tokensMode
+	astMode
+	sexprMode
+)
+
+// session is the state of one REPL: its output, mode and environment.
+type session struct {
+	out    *bufio.Writer
+	mode   mode
+	env    *object.Environment
+	timing bool
+
+	// color is set when the session is on a terminal, to colour errors.
+	color bool
+
+	// inputs holds each input evaluated without error since the last
+	// reset, formatted, for :save.
+	inputs []string
+}
+
+type command struct {
+	name  string
+	arg   string
+	short string
+	run   func(s *session, arg string)
+}
+
+var commands []command
+
+func (cmd command) usage() string {
+	return strings.TrimSpace(":" + cmd.name + " " + cmd.arg)
+}
+
+func init() {
+	// Assigned here rather than in the declaration, since :help refers back
+	// to commands.
+	commands = []command{
+		{"eval", "", "evaluate each line and show its value", setMode(evalMode)},
+		{"tokens", "", "show the tokens of each line", setMode(tokensMode)},
+		{"ast", "", "show the syntax tree of each line", setMode(astMode)},
+		{"sexpr", "", "show the syntax tree of each line as S-expressions", setMode(sexprMode)},
+		{"env", "", "list the names bound by evaluated lines", (*session).printEnv},
+		{"load", "file", "handle the contents of file as if it were typed in", (*session).load},
+		{"save", "file", "write the lines evaluated without error to file", (*session).save},
+		{"restore", "file", "evaluate the program in file, as saved by :save", (*session).restore},
+		{"reset", "", "forget all bindings and evaluated lines", (*session).reset},
+		{"time", "", "toggle showing how long each line takes", func(s *session, _ string) { s.timing = !s.timing }},
+		{"help", "", "list the commands", (*session).help},
+	}
+}
+
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	writer := bufio.NewWriter(out)
+	s := &session{out: bufio.NewWriter(out)}
+	s.env = s.newEnvironment()
+	reader := newLineReader(in, out, s)
 
 	for {
-		fmt.Fprintf(writer, Prompt)
-		writer.Flush()
+		s.out.Flush()
 
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := reader.ReadLine(Prompt)
+		if err == lineedit.ErrInterrupted {
+			continue
+		}
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.NewLexer(line)
-		p := parser.New(l)
+		if err := reader.AddHistory(line); err != nil {
+			fmt.Fprintf(s.out, "history: %v\n", err)
+		}
+		if strings.HasPrefix(line, ":") {
+			s.command(line[1:])
+		} else {
+			s.handle("", line)
+		}
+	}
+}
 
-		program := p.ParseProgram()
-		if len(p.Errors) != 0 {
-			printParseErrors(writer, p.Errors)
+func setMode(m mode) func(*session, string) {
+	return func(s *session, _ string) { s.mode = m }
+}
+
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+
+	for _, cmd := range commands {
+		if cmd.name != name {
 			continue
 		}
+		if arg != "" && cmd.arg == "" || arg == "" && cmd.arg != "" {
+			fmt.Fprintf(s.out, "usage: %s\n", cmd.usage())
+			return
+		}
+		cmd.run(s, arg)
+		return
+	}
+	fmt.Fprintf(s.out, "unknown command :%s, try :help\n", name)
+}
+
+// handle shows or evaluates one piece of input according to the mode. Any
+// errors in it are reported under name, which is empty for a typed line.
+func (s *session) handle(name, input string) {
+	start := time.Now()
+	defer func() {
+		if s.timing {
+			fmt.Fprintf(s.out, "(%s)\n", time.Since(start))
+		}
+	}()
+
+	if s.mode == tokensMode {
+		l := lexer.NewLexer(input)
+		for tok := l.NextToken(); tok.Type != lexer.Eof; tok = l.NextToken() {
+			fmt.Fprintf(s.out, "%s %q\n", tok.Type, tok.Literal)
+		}
+		return
+	}
 
-		io.WriteString(writer, program.String())
-		io.WriteString(writer, "\n")
+	file := diag.NewFile(name, input)
+	p := parser.New(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		s.report(file, p.Diagnostics)
+		return
 	}
+	if s.mode != evalMode {
+		s.report(file, p.Diagnostics)
+	}
+
+	switch s.mode {
+	case astMode:
+		io.WriteString(s.out, program.String())
+		io.WriteString(s.out, "\n")
+	case sexprMode:
+		if len(program.Statements) > 0 {
+			io.WriteString(s.out, ast.SExpr(program))
+			io.WriteString(s.out, "\n")
+		}
+	case evalMode:
+		if result, _ := s.eval(file, program, p.Diagnostics); result != nil {
+			io.WriteString(s.out, result.Inspect())
+			io.WriteString(s.out, "\n")
+		}
+	}
+}
+
+// eval evaluates program, from file, in the session's environment and, if
+// that succeeds, records it for :save. A program that uses names that are
+// not bound is reported and not evaluated at all, and has no result. The
+// parser's warnings are reported with those names, less any at the same
+// place as one.
+func (s *session) eval(file *diag.File, program *ast.Program, warnings []diag.Diagnostic) (object.Object, bool) {
+	config := resolver.Config{Predeclared: s.env.Names(), Open: true}
+	resolved := resolver.Resolve(program, config)
+	s.report(file, diag.Merge(warnings, resolved.Diagnostics))
+	if len(resolved.Diagnostics) > 0 {
+		return nil, false
+	}
+
+	result := evaluator.Eval(program, s.env)
+	if _, failed := result.(*object.Error); failed {
+		return result, false
+	}
+	if len(program.Statements) > 0 {
+		s.inputs = append(s.inputs, printer.String(program))
+	}
+	return result, true
+}
+
+func (s *session) printEnv(string) {
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+	}
+}
+
+func (s *session) load(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	s.handle(file, string(data))
+}
+
+func (s *session) save(file string) {
+	if err := os.WriteFile(file, []byte(strings.Join(s.inputs, "")), 0o644); err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.inputs), file)
+}
+
+// restore evaluates the program in file whatever the mode, showing only
+// what it prints and any error, so that a saved session can be picked up
+// where it was left.
+func (s *session) restore(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	source := diag.NewFile(file, string(data))
+	p := parser.New(lexer.NewLexer(string(data)))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		s.report(source, p.Diagnostics)
+		return
+	}
+	if result, ok := s.eval(source, program, p.Diagnostics); !ok && result != nil {
+		io.WriteString(s.out, result.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// newEnvironment returns an empty environment in which puts writes through
+// the session's buffer, so that its output is interleaved correctly with
+// the values shown.
+func (s *session) newEnvironment() *object.Environment {
+	env := object.NewEnvironment()
+	env.SetOutput(s.out)
+	return env
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func (s *session) reset(string) {
+	s.env = s.newEnvironment()
+	s.inputs = nil
+}
+
+func (s *session) help(string) {
+	for _, cmd := range commands {
+		fmt.Fprintf(s.out, "  %-14s %s\n", cmd.usage(), cmd.short)
 	}
 }
+
+func (s *session) report(file *diag.File, diagnostics []diag.Diagnostic) {
+	diag.Renderer{Color: s.color}.Render(s.out, file, diagnostics...)
+}
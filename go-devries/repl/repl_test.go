@@ -0,0 +1,176 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/object"
+)
+
+// run runs the REPL on the given lines and returns what it wrote,
+// without the prompts.
+func run(lines ...string) string {
+	var out strings.Builder
+	Start(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out)
+	return strings.ReplaceAll(out.String(), Prompt, "")
+}
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		lines    []string
+		expected string
+	}{
+		{[]string{"let x = 1 + 2 * 3", "x"}, "7\n"},
+		{[]string{":ast", "let x = 1 + 2 * 3"}, "let x = (1 + (2 * 3));\n"},
+		{[]string{":sexpr", "let x = 1 + 2 * 3"}, "(let x (+ 1 (* 2 3)))\n"},
+		{[]string{":tokens", "let x = 1;"}, "Let \"let\"\nIdent \"x\"\nAssign \"=\"\nInt \"1\"\nSemicolon \";\"\n"},
+		{[]string{"let x = 2", "x * 21", "puts(x)"}, "42\n2\nnull\n"},
+		{[]string{"1 + true"}, "ERROR: type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{"let = 1"}, "expected next token to be Ident, got Assign instead\n1 | let = 1\n  |     ^\n" +
+			"no prefix parse function for Assign found\n1 | let = 1\n  |     ^\n"},
+		{[]string{":eval", "let x = 1", ":sexpr", "x", ":eval", "x"}, "x\n1\n"},
+	}
+
+	for _, tt := range tests {
+		if got := run(tt.lines...); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.lines, tt.expected, got)
+		}
+	}
+}
+
+func TestEnvAndReset(t *testing.T) {
+	got := run(":eval", "let b = [1, 2]", "let a = fn(x) { x }", ":env", ":reset", ":env", "b")
+	expected := "a = fn(x) {\nx\n}\nb = [1, 2]\nidentifier not found: b\n1 | b\n  | ^\n"
+	if got != expected {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lib.mk")
+	if err := os.WriteFile(file, []byte("let double = fn(x) { x * 2 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := run(":eval", ":load "+file, "double(21)", ":load")
+	if expected := "42\nusage: :load file\n"; got != expected {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+
+	broken := filepath.Join(t.TempDir(), "broken.mk")
+	if err := os.WriteFile(broken, []byte("let x = 1;\nlet y = ;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(":load " + broken); !strings.HasPrefix(got, broken+":2:9: no prefix parse function for Semicolon found\n2 | let y = ;\n") {
+		t.Errorf("expected the error to be reported in %s, got %q", broken, got)
+	}
+
+	if got := run(":load " + filepath.Join(t.TempDir(), "missing.mk")); !strings.Contains(got, "no such file") {
+		t.Errorf("expected an error for a missing file, got %q", got)
+	}
+}
+
+func TestTime(t *testing.T) {
+	got := run(":time", "1", ":time", "2")
+	lines := strings.Split(got, "\n")
+	if len(lines) != 4 || lines[0] != "1" || !strings.HasPrefix(lines[1], "(") || lines[2] != "2" {
+		t.Errorf("expected a time after the first line only, got %q", got)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if got := run(":frobnicate", ":help extra"); got != "unknown command :frobnicate, try :help\nusage: :help\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := run(":help"); !strings.Contains(got, ":load file") {
+		t.Errorf(":help does not list :load, got %q", got)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := &session{env: object.NewEnvironment()}
+	s.env.Set("length", &object.Integer{Value: 1})
+	s.env.Set("letter", &object.Integer{Value: 2})
+
+	tests := []struct {
+		line     string
+		start    int
+		expected []string
+	}{
+		{"le", 0, []string{"len", "length", "let", "letter"}},
+		{"1 + put", 4, []string{"puts"}},
+		{"(fals", 1, []string{"false"}},
+		{":lo", 1, []string{"load"}},
+		{":load le", 6, nil},
+		{"zzz", 0, nil},
+	}
+
+	for _, tt := range tests {
+		start, got := s.complete(tt.line, len(tt.line))
+		if start != tt.start || strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("%q: expected %d %q, got %d %q", tt.line, tt.start, tt.expected, start, got)
+		}
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.mk")
+
+	got := run(
+		"let add = fn(a, b) { a + b }",
+		"let x = add(1, 2)",
+		"y",
+		"let z = 1 +",
+		"puts(x)",
+		":ast",
+		"let ignored = 1",
+		":save "+file,
+	)
+	expected := "identifier not found: y\n1 | y\n  | ^\nno prefix parse function for Eof found\n1 | let z = 1 +\n  |            ^\n" +
+		"  = note: the input ends before the expression does\n3\nnull\nlet ignored = 1;\nsaved 3 inputs to " + file + "\n"
+	if got != expected {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "let add = fn(a, b) {\n\ta + b;\n};\nlet x = add(1, 2);\nputs(x);\n"
+	if string(data) != script {
+		t.Errorf("expected the script %q, got %q", script, data)
+	}
+
+	// Restoring evaluates the script whatever the mode, showing only what
+	// it prints.
+	if got := run(":ast", ":restore "+file, ":eval", "add(x, 4)"); got != "3\n7\n" {
+		t.Errorf("expected the session to be restored, got %q", got)
+	}
+
+	if got := run(":reset", ":save "+file); got != "saved 0 inputs to "+file+"\n" {
+		t.Errorf("expected :reset to forget the inputs, got %q", got)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	// The parser's warning about retrun is only shown once retrun is bound,
+	// when it is no longer the same as the error about it.
+	got := run("let counter = 1", "countr + 1", "retrun counter", "let retrun = 2", "retrun counter")
+	expected := "identifier not found: countr\n1 | countr + 1\n  | ^^^^^^\n  = help: did you mean counter?\n" +
+		"identifier not found: retrun\n1 | retrun counter\n  | ^^^^^^\n  = help: did you mean return?\n" +
+		"warning: retrun is not a keyword\n1 | retrun counter\n  | ^^^^^^\n  = help: did you mean return?\n1\n"
+	if got != expected {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestUndefinedNames(t *testing.T) {
+	// A line that uses an unbound name is not run at all, so it has no
+	// effects, even ones that come before the name.
+	got := run(`puts("before")`, `puts("side effect"); missing`, "let f = fn() { later() }", "let later = fn() { 1 }", "f()")
+	if expected := "before\nnull\nidentifier not found: missing\n1 | puts(\"side effect\"); missing\n  |                      ^^^^^^^\n1\n"; got != expected {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+}
@@ -0,0 +1,321 @@
+// Package resolver works out, before a program runs, which declaration each
+// name in it refers to, and reports the names that refer to none.
+//
+// Scopes follow the environments of the evaluator. The program has one
+// scope, nested in a universe scope that holds the builtins; a function
+// literal has one for its parameters and body; and each branch of an if
+// expression has its own. A name can only be used after its declaration,
+// except in a function body, which can also refer to names declared later
+// in enclosing scopes, since it only runs when the function is called:
+//
+//	let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+//	let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+package resolver
+
+import (
+	"fmt"
+
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/evaluator"
+	"monkey/lexer"
+)
+
+// Kind tells how a name was declared.
+type Kind int
+
+const (
+	// Let is a name declared by a let statement.
+	Let Kind = iota
+	// Parameter is a parameter of a function literal.
+	Parameter
+	// Predeclared is a name given to Resolve, such as one bound by an
+	// earlier line in the REPL.
+	Predeclared
+	// Builtin is a builtin function.
+	Builtin
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Let:
+		return "let"
+	case Parameter:
+		return "parameter"
+	case Predeclared:
+		return "predeclared"
+	case Builtin:
+		return "builtin"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Declaration is one declaration of a name.
+type Declaration struct {
+	Name  string
+	Kind  Kind
+	Scope *Scope
+
+	// Ident is the declaring identifier: the name of a let statement or a
+	// parameter. It is nil for predeclared names and builtins.
+	Ident *ast.Identifier
+
+	// order is the position of the declaration in the walk of the
+	// program, to compare with that of references.
+	order int
+}
+
+// Scope is a region of the program in which declarations are visible.
+type Scope struct {
+	// Node is the node that opens the scope: nil for the universe, the
+	// *ast.Program, an *ast.FunctionLiteral or the *ast.BlockStatement of
+	// a branch of an if expression.
+	Node     ast.Node
+	Parent   *Scope
+	Children []*Scope
+
+	// Declarations holds the declarations made directly in the scope, in
+	// the order they appear. A name declared twice appears twice.
+	Declarations []*Declaration
+
+	// functions counts the function literals the scope is inside,
+	// including the one that opens it.
+	functions int
+}
+
+// Lookup returns the last declaration of name in s or, failing that, in
+// the scopes enclosing it, or nil if there is none. This is what name
+// refers to at the end of s.
+func (s *Scope) Lookup(name string) *Declaration {
+	for ; s != nil; s = s.Parent {
+		for i := len(s.Declarations) - 1; i >= 0; i-- {
+			if d := s.Declarations[i]; d.Name == name {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// Result is what Resolve found out about a program.
+type Result struct {
+	// Universe is the outermost scope, holding the builtins. The scope
+	// of the program is its only child.
+	Universe *Scope
+
+	// References maps each identifier that refers to a declaration to
+	// that declaration. Identifiers that declare names are not included,
+	// and neither are those that refer to nothing.
+	References map[*ast.Identifier]*Declaration
+
+	// Declarations maps each identifier that declares a name to its
+	// declaration.
+	Declarations map[*ast.Identifier]*Declaration
+
+	// Diagnostics reports each identifier that refers to nothing.
+	Diagnostics []diag.Diagnostic
+}
+
+// Program returns the scope of the program.
+func (r *Result) Program() *Scope {
+	return r.Universe.Children[0]
+}
+
+// reference is a use of a name, resolved once the whole program has been
+// walked, when the declarations that follow it are known too.
+type reference struct {
+	ident  *ast.Identifier
+	scope  *Scope
+	order  int
+	assign bool
+}
+
+// Config changes how Resolve treats names that are not declared in the
+// program.
+type Config struct {
+	// Predeclared names are taken to be declared before the program
+	// starts, such as those bound by earlier lines in the REPL.
+	Predeclared []string
+
+	// Open is set when more of the program may follow, as in the REPL. A
+	// name that a function body uses and that is declared nowhere might
+	// then be declared before the function is called, so it is not
+	// reported.
+	Open bool
+}
+
+type resolver struct {
+	config     Config
+	result     *Result
+	scope      *Scope
+	order      int
+	references []reference
+}
+
+// Resolve resolves the names in program.
+func Resolve(program *ast.Program, config Config) *Result {
+	r := &resolver{config: config, result: &Result{
+		Universe:     &Scope{},
+		References:   map[*ast.Identifier]*Declaration{},
+		Declarations: map[*ast.Identifier]*Declaration{},
+	}}
+
+	r.scope = r.result.Universe
+	for _, name := range evaluator.BuiltinNames() {
+		r.declare(name, Builtin, nil)
+	}
+
+	r.open(program)
+	for _, name := range config.Predeclared {
+		r.declare(name, Predeclared, nil)
+	}
+	ast.Walk(r, program)
+
+	for _, ref := range r.references {
+		r.resolve(ref)
+	}
+	return r.result
+}
+
+// open starts a scope opened by node, nested in the current one.
+func (r *resolver) open(node ast.Node) {
+	s := &Scope{Node: node, Parent: r.scope, functions: r.scope.functions}
+	if _, ok := node.(*ast.FunctionLiteral); ok {
+		s.functions++
+	}
+	r.scope.Children = append(r.scope.Children, s)
+	r.scope = s
+}
+
+func (r *resolver) close() {
+	r.scope = r.scope.Parent
+}
+
+func (r *resolver) declare(name string, kind Kind, ident *ast.Identifier) {
+	r.order++
+	d := &Declaration{Name: name, Kind: kind, Scope: r.scope, Ident: ident, order: r.order}
+	r.scope.Declarations = append(r.scope.Declarations, d)
+	if ident != nil {
+		r.result.Declarations[ident] = d
+	}
+}
+
+func (r *resolver) use(ident *ast.Identifier, assign bool) {
+	r.order++
+	r.references = append(r.references, reference{ident, r.scope, r.order, assign})
+}
+
+// Visit resolves the names under node. It walks the nodes that declare
+// names itself, to declare them at the right point.
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		// The value is resolved first, so that in let x = x + 1 the x on
+		// the right is an earlier one.
+		ast.Walk(r, node.Value)
+		if node.Name != nil {
+			r.declare(node.Name.Value, Let, node.Name)
+		}
+		return nil
+	case *ast.FunctionLiteral:
+		// The parameters and the body share a scope, as they share an
+		// environment when the function is called.
+		r.open(node)
+		for _, param := range node.Parameters {
+			r.declare(param.Value, Parameter, param)
+		}
+		ast.Walk(r, node.Body)
+		r.close()
+		return nil
+	case *ast.IfExpression:
+		ast.Walk(r, node.Condition)
+		r.block(node.Consequence)
+		r.block(node.Alternative)
+		return nil
+	case *ast.AssignExpression:
+		if ident, ok := node.Target.(*ast.Identifier); ok && ident != nil {
+			r.use(ident, true)
+		} else {
+			ast.Walk(r, node.Target)
+		}
+		ast.Walk(r, node.Value)
+		return nil
+	case *ast.Identifier:
+		r.use(node, false)
+	}
+	return r
+}
+
+// block resolves a branch of an if expression in a scope of its own.
+func (r *resolver) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	r.open(block)
+	ast.Walk(r, block)
+	r.close()
+}
+
+// resolve finds the declaration ref refers to, or reports that there is
+// none.
+func (r *resolver) resolve(ref reference) {
+	name := ref.ident.Value
+	for s := ref.scope; s != nil; s = s.Parent {
+		// Code in the same function as the scope runs in order, but a
+		// function nested in it may be called after anything in the scope.
+		d := s.find(name, ref.order, s.functions < ref.scope.functions)
+		if d == nil {
+			continue
+		}
+		if ref.assign && d.Kind == Builtin {
+			// The evaluator only assigns to names in an environment.
+			break
+		}
+		r.result.References[ref.ident] = d
+		return
+	}
+	if r.config.Open && ref.scope.functions > 0 {
+		return
+	}
+
+	msg := fmt.Sprintf("identifier not found: %s", name)
+	var candidates []string
+	if ref.assign {
+		msg = fmt.Sprintf("assignment to undeclared identifier: %s", name)
+	} else {
+		candidates = lexer.Keywords()
+	}
+	for s := ref.scope; s != nil; s = s.Parent {
+		for _, d := range s.Declarations {
+			if !ref.assign || d.Kind != Builtin {
+				candidates = append(candidates, d.Name)
+			}
+		}
+	}
+	suggestion, _ := diag.Closest(name, candidates)
+
+	r.result.Diagnostics = append(r.result.Diagnostics, diag.Diagnostic{
+		Severity:   diag.Error,
+		Span:       ref.ident.Span,
+		Message:    msg,
+		Suggestion: suggestion,
+	})
+}
+
+// find returns the last declaration of name in s before order. If there is
+// none and later is set, it returns the first one after order instead.
+func (s *Scope) find(name string, order int, later bool) *Declaration {
+	var found *Declaration
+	for _, d := range s.Declarations {
+		if d.Name != name {
+			continue
+		}
+		if d.order < order {
+			found = d
+		} else if found == nil && later {
+			return d
+		}
+	}
+	return found
+}
@@ -0,0 +1,238 @@
+package resolver
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/astgen"
+	"monkey/diag"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/printer"
+)
+
+func resolve(t *testing.T, input string, config Config) (*ast.Program, *Result) {
+	t.Helper()
+	p := parser.New(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors) > 0 {
+		t.Fatalf("%q: parse errors %q", input, p.Errors)
+	}
+	return program, Resolve(program, config)
+}
+
+// identifiers returns the identifiers in the program named name, or all of
+// them if name is empty, in the order they appear.
+func identifiers(node ast.Node, name string) []*ast.Identifier {
+	var found []*ast.Identifier
+	ast.Inspect(node, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok && (name == "" || ident.Value == name) {
+			found = append(found, ident)
+		}
+		return true
+	})
+	return found
+}
+
+func TestUndefined(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x + 1", nil},
+		{"y", []string{"identifier not found: y"}},
+		{"x; let x = 1;", []string{"identifier not found: x"}},
+		{"let x = x + 1;", []string{"identifier not found: x"}},
+		{"let f = fn(a, b) { a + b }; f(1, c)", []string{"identifier not found: c"}},
+		{"let f = fn() { g() }; let g = fn() { 1 };", nil},
+		{"let f = fn() { let h = fn() { k }; let k = 1; h() };", nil},
+		{"let f = fn() { k; let k = 1; };", []string{"identifier not found: k"}},
+		{"if (true) { let y = 1; y }; y", []string{"identifier not found: y"}},
+		{"if (true) { let y = 1 } else { y }", []string{"identifier not found: y"}},
+		{"let f = fn() { if (true) { let z = 1 }; z };", []string{"identifier not found: z"}},
+		{"let f = fn(n) { if (n == 0) { 1 } else { n * f(n - 1) } }; f(5)", nil},
+		{"len(puts)", nil},
+		{`"${missing} and ${len}"`, []string{"identifier not found: missing"}},
+		{"x = 1", []string{"assignment to undeclared identifier: x"}},
+		{"len = 1", []string{"assignment to undeclared identifier: len"}},
+		{"let x = 1; let f = fn() { x += 1 }; x = 2; let a = [x]; a[0] = 3", nil},
+		{"q[0] = 1", []string{"identifier not found: q"}},
+		{"a; b", []string{"identifier not found: a", "identifier not found: b"}},
+	}
+
+	for _, tt := range tests {
+		_, result := resolve(t, tt.input, Config{})
+		var got []string
+		for _, d := range result.Diagnostics {
+			got = append(got, d.Message)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestPredeclared(t *testing.T) {
+	_, result := resolve(t, "counter + 1", Config{Predeclared: []string{"counter"}})
+	if len(result.Diagnostics) != 0 {
+		t.Errorf("expected counter to be predeclared, got %v", result.Diagnostics)
+	}
+
+	if d := result.Program().Lookup("counter"); d == nil || d.Kind != Predeclared {
+		t.Errorf("expected a predeclared declaration of counter, got %+v", d)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	input := "let f = fn() { later() + if (true) { again } }; missing; f()"
+	_, result := resolve(t, input, Config{Open: true})
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Message != "identifier not found: missing" {
+		t.Errorf("expected only missing to be reported, got %v", result.Diagnostics)
+	}
+
+	if _, result := resolve(t, input, Config{}); len(result.Diagnostics) != 3 {
+		t.Errorf("expected all three names to be reported, got %v", result.Diagnostics)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	_, result := resolve(t, "let counter = 1;\nlet f = fn(value) { valeu + countr };", Config{})
+	if len(result.Diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", result.Diagnostics)
+	}
+
+	expected := []struct {
+		start, end int
+		suggestion string
+	}{
+		{37, 42, "value"},
+		{45, 51, "counter"},
+	}
+	for i, exp := range expected {
+		d := result.Diagnostics[i]
+		if d.Span.Start != exp.start || d.Span.End != exp.end || d.Suggestion != exp.suggestion {
+			t.Errorf("diagnostic %d: expected %d-%d suggesting %q, got %+v", i, exp.start, exp.end, exp.suggestion, d)
+		}
+	}
+}
+
+// TestHandBuiltDiagnostics checks that a name in an AST not built by the
+// parser, which has no span, is reported at no place rather than at the
+// start of the source.
+func TestHandBuiltDiagnostics(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "nowhere"}},
+	}}
+	result := Resolve(program, Config{})
+	if len(result.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", result.Diagnostics)
+	}
+
+	expected := "a.mk: identifier not found: nowhere\n"
+	if got := diag.String(diag.NewFile("a.mk", "nowhere"), result.Diagnostics[0]); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+// TestReferences checks the declaration each use of x refers to, given as
+// the index among the identifiers named x of the declaring one.
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[int]int
+	}{
+		{"let x = 1; x", map[int]int{1: 0}},
+		{"let x = 1; let x = x + 1; x", map[int]int{2: 0, 3: 1}},
+		{"let x = 1; let f = fn(x) { x }; x", map[int]int{2: 1, 3: 0}},
+		{"let f = fn() { x }; let x = 1; let x = 2;", map[int]int{0: 1}},
+		{"let x = 1; let f = fn() { x }; let x = 2;", map[int]int{1: 0}},
+		{"let x = 1; if (x) { let x = 2; x } else { x }", map[int]int{1: 0, 3: 2, 4: 0}},
+		{"let x = 1; x += 1; x = 3", map[int]int{1: 0, 2: 0}},
+	}
+
+	for _, tt := range tests {
+		program, result := resolve(t, tt.input, Config{})
+		xs := identifiers(program, "x")
+		for use, decl := range tt.expected {
+			d := result.References[xs[use]]
+			if d == nil || d.Ident != xs[decl] {
+				t.Errorf("%q: expected x %d to refer to x %d, got %+v", tt.input, use, decl, d)
+			}
+		}
+		for i, x := range xs {
+			if _, ok := tt.expected[i]; !ok && result.Declarations[x] == nil {
+				t.Errorf("%q: expected x %d to be a declaration", tt.input, i)
+			}
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	program, result := resolve(t, "let a = 1; let f = fn(b, c) { let d = len; if (b) { let e = 1 } }; f(a, 2)", Config{})
+
+	root := result.Program()
+	if root.Node != program || root.Parent != result.Universe {
+		t.Fatalf("expected the program scope to be in the universe")
+	}
+	if d := result.Universe.Lookup("len"); d == nil || d.Kind != Builtin {
+		t.Errorf("expected len to be a builtin, got %+v", d)
+	}
+
+	names := func(s *Scope) string {
+		var names []string
+		for _, d := range s.Declarations {
+			names = append(names, d.Kind.String()+" "+d.Name)
+		}
+		return strings.Join(names, ", ")
+	}
+
+	if got := names(root); got != "let a, let f" {
+		t.Errorf("program scope: got %q", got)
+	}
+	if len(root.Children) != 1 {
+		t.Fatalf("expected one scope in the program, got %d", len(root.Children))
+	}
+	fn := root.Children[0]
+	if _, ok := fn.Node.(*ast.FunctionLiteral); !ok || names(fn) != "parameter b, parameter c, let d" {
+		t.Errorf("function scope: got %T with %q", fn.Node, names(fn))
+	}
+	if len(fn.Children) != 1 || names(fn.Children[0]) != "let e" {
+		t.Fatalf("expected a block scope declaring e in the function")
+	}
+	if d := fn.Children[0].Lookup("a"); d == nil || d.Scope != root {
+		t.Errorf("expected a to be found in the program scope from the block, got %+v", d)
+	}
+
+	lens := identifiers(program, "len")
+	if d := result.References[lens[0]]; d == nil || d.Kind != Builtin || d.Ident != nil {
+		t.Errorf("expected len to refer to the builtin, got %+v", d)
+	}
+}
+
+// TestGeneratedPrograms checks the resolver against random programs, which
+// use the names they declare, such as x1 and p2, only where they are in
+// scope, and otherwise names that are never declared, such as y3.
+func TestGeneratedPrograms(t *testing.T) {
+	g := astgen.New(rand.New(rand.NewSource(1)), astgen.Full())
+
+	for i := 0; i < 1000; i++ {
+		program := g.Program()
+		result := Resolve(program, Config{})
+
+		var undefined []string
+		for _, d := range result.Diagnostics {
+			undefined = append(undefined, d.Message[strings.LastIndex(d.Message, " ")+1:])
+		}
+		var expected []string
+		for _, ident := range identifiers(program, "") {
+			if strings.HasPrefix(ident.Value, "y") {
+				expected = append(expected, ident.Value)
+			}
+		}
+		if strings.Join(undefined, " ") != strings.Join(expected, " ") {
+			t.Fatalf("expected %v to be undefined, got %v in:\n%s", expected, undefined, printer.String(program))
+		}
+	}
+}
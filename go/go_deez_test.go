@@ -80,3 +80,125 @@ func TestGetNextTokenComplete(t *testing.T) {
 		}
 	}
 }
+
+func TestGetNextTokenOperatorsAndKeywords(t *testing.T) {
+	const input = `!-/*5;
+	5 < 10 > 5;
+
+	if (5 < 10) {
+		return true;
+	} else {
+		return false;
+	}
+
+	10 == 10;
+	10 != 9;`
+
+	var lexer = NewTokenizer(input)
+
+	var tokens []Token
+
+	tokens = append(tokens, CreateToken(Bang, "!"),
+		CreateToken(Minus, "-"),
+		CreateToken(Slash, "/"),
+		CreateToken(Asterisk, "*"),
+		CreateToken(Int, "5"),
+		CreateToken(Semicolon, ";"),
+
+		CreateToken(Int, "5"),
+		CreateToken(LessThan, "<"),
+		CreateToken(Int, "10"),
+		CreateToken(GreaterThan, ">"),
+		CreateToken(Int, "5"),
+		CreateToken(Semicolon, ";"),
+
+		CreateToken(If, "if"),
+		CreateToken(LParen, "("),
+		CreateToken(Int, "5"),
+		CreateToken(LessThan, "<"),
+		CreateToken(Int, "10"),
+		CreateToken(RParen, ")"),
+		CreateToken(LSquirly, "{"),
+		CreateToken(Return, "return"),
+		CreateToken(True, "true"),
+		CreateToken(Semicolon, ";"),
+		CreateToken(RSquirly, "}"),
+		CreateToken(Else, "else"),
+		CreateToken(LSquirly, "{"),
+		CreateToken(Return, "return"),
+		CreateToken(False, "false"),
+		CreateToken(Semicolon, ";"),
+		CreateToken(RSquirly, "}"),
+
+		CreateToken(Int, "10"),
+		CreateToken(Eq, "=="),
+		CreateToken(Int, "10"),
+		CreateToken(Semicolon, ";"),
+		CreateToken(Int, "10"),
+		CreateToken(NotEq, "!="),
+		CreateToken(Int, "9"),
+		CreateToken(Semicolon, ";"),
+		CreateToken(Eof, "eof"))
+
+	for _, token := range tokens {
+		var nextToken = lexer.GetNextToken()
+		if nextToken != token {
+			t.Errorf("Token %s expected %s", nextToken, token)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	var lexer Iterator = func() *Tokenizer {
+		var tokenizer = NewTokenizer("let x = @;")
+		return &tokenizer
+	}()
+
+	var types = []TokenType{Let, Ident, Equal, Illegal, Semicolon, Eof}
+	var literals = []string{"let", "x", "=", "@", ";", "eof"}
+
+	for i, type_ := range types {
+		var token, ok = lexer.Next()
+		if !ok {
+			t.Fatalf("Next ended before %s", type_)
+		}
+		if token.Type() != type_ || token.Literal() != literals[i] {
+			t.Errorf("Token %s expected %s %q", token, type_, literals[i])
+		}
+	}
+
+	if token, ok := lexer.Next(); ok {
+		t.Errorf("Token %s expected the end", token)
+	}
+}
+
+func TestGetNextTokenUnicode(t *testing.T) {
+	const input = "let café = 名前_2 + x٣;\u2028\u0301 € \u00a0 ok"
+
+	var lexer = NewTokenizer(input)
+
+	var tokens []Token
+
+	tokens = append(tokens, CreateToken(Let, "let"),
+		CreateToken(Ident, "café"),
+		CreateToken(Equal, "="),
+		CreateToken(Ident, "名前_2"),
+		CreateToken(Plus, "+"),
+		CreateToken(Ident, "x٣"),
+		CreateToken(Semicolon, ";"),
+
+		// A combining mark cannot start an identifier, and neither
+		// symbols nor the no-break space are allowed outside strings.
+		CreateToken(Illegal, "\u0301"),
+		CreateToken(Illegal, "€"),
+		CreateToken(Illegal, "\u00a0"),
+		CreateToken(Ident, "ok"),
+		CreateToken(Eof, "eof"))
+
+	for _, token := range tokens {
+		var nextToken = lexer.GetNextToken()
+		if nextToken != token {
+			t.Errorf("Token %s expected %s", nextToken, token)
+		}
+	}
+}
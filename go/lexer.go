@@ -1,22 +1,40 @@
 package go_deez
 
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
 type TokenType string
 
 const (
-	Illegal   TokenType = "ILLEGAL"
-	Eof       TokenType = "EOF"
-	Ident     TokenType = "IDENT"
-	Int       TokenType = "INT"
-	Equal     TokenType = "="
-	Plus      TokenType = "+"
-	Comma     TokenType = ","
-	Semicolon TokenType = ";"
-	LParen    TokenType = "("
-	RParen    TokenType = ")"
-	LSquirly  TokenType = "{"
-	RSquirly  TokenType = "}"
-	Function  TokenType = "FUNCTION"
-	Let       TokenType = "LET"
+	Illegal     TokenType = "ILLEGAL"
+	Eof         TokenType = "EOF"
+	Ident       TokenType = "IDENT"
+	Int         TokenType = "INT"
+	Equal       TokenType = "="
+	Plus        TokenType = "+"
+	Minus       TokenType = "-"
+	Bang        TokenType = "!"
+	Asterisk    TokenType = "*"
+	Slash       TokenType = "/"
+	LessThan    TokenType = "<"
+	GreaterThan TokenType = ">"
+	Eq          TokenType = "=="
+	NotEq       TokenType = "!="
+	Comma       TokenType = ","
+	Semicolon   TokenType = ";"
+	LParen      TokenType = "("
+	RParen      TokenType = ")"
+	LSquirly    TokenType = "{"
+	RSquirly    TokenType = "}"
+	Function    TokenType = "FUNCTION"
+	Let         TokenType = "LET"
+	True        TokenType = "TRUE"
+	False       TokenType = "FALSE"
+	If          TokenType = "IF"
+	Else        TokenType = "ELSE"
+	Return      TokenType = "RETURN"
 )
 
 type Token struct {
@@ -28,6 +46,23 @@ func CreateToken(type_ TokenType, literal string) Token {
 	return Token{type_: type_, literal: literal}
 }
 
+// Type returns the type of the token.
+func (token Token) Type() TokenType {
+	return token.type_
+}
+
+// Literal returns the text of the token.
+func (token Token) Literal() string {
+	return token.literal
+}
+
+// Iterator is the shape shared with the iterators of the other Monkey
+// lexers: Next returns each token in turn, up to and including the Eof
+// token, and then false.
+type Iterator interface {
+	Next() (Token, bool)
+}
+
 const _0 = int('0')
 const _9 = int('9')
 
@@ -39,11 +74,58 @@ const Z = int('Z')
 
 const __ = int('_')
 
+// Identifiers follow the rule shared by the Monkey lexers, the default
+// identifier syntax of Unicode Standard Annex #31: an ID_Start character or
+// an underscore, then any number of ID_Continue characters. Whitespace is
+// the Pattern_White_Space set. The tables are a copy of those in
+// go-devries/lexer/ident.go, which this module does not depend on;
+// TestCharacterClasses in go-conformance fails if the two disagree.
+
+var idStart = []*unicode.RangeTable{
+	unicode.L,
+	unicode.Nl,
+	unicode.Other_ID_Start,
+}
+
+var idContinue = []*unicode.RangeTable{
+	unicode.L,
+	unicode.Nl,
+	unicode.Other_ID_Start,
+	unicode.Mn,
+	unicode.Mc,
+	unicode.Nd,
+	unicode.Pc,
+	unicode.Other_ID_Continue,
+}
+
 func isLetter(character rune) bool {
 	var char = int(character)
-	return a <= char && z >= char ||
-		A <= char && Z >= char ||
-		char == __
+	if char < utf8.RuneSelf {
+		return a <= char && z >= char ||
+			A <= char && Z >= char ||
+			char == __
+	}
+	return unicode.IsOneOf(idStart, character) && !isPattern(character)
+}
+
+func isLetterOrDigit(character rune) bool {
+	if character < utf8.RuneSelf {
+		return isLetter(character) || isNumber(character)
+	}
+	return unicode.IsOneOf(idContinue, character) && !isPattern(character)
+}
+
+func isPattern(character rune) bool {
+	return unicode.Is(unicode.Pattern_Syntax, character) ||
+		unicode.Is(unicode.Pattern_White_Space, character)
+}
+
+func isWhitespace(character rune) bool {
+	switch character {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return character >= utf8.RuneSelf && unicode.Is(unicode.Pattern_White_Space, character)
 }
 
 func isNumber(character rune) bool {
@@ -52,17 +134,29 @@ func isNumber(character rune) bool {
 }
 
 var Keyword = map[string]Token{
-	"fn":  CreateToken(Function, "fn"),
-	"let": CreateToken(Let, "let"),
+	"fn":     CreateToken(Function, "fn"),
+	"let":    CreateToken(Let, "let"),
+	"true":   CreateToken(True, "true"),
+	"false":  CreateToken(False, "false"),
+	"if":     CreateToken(If, "if"),
+	"else":   CreateToken(Else, "else"),
+	"return": CreateToken(Return, "return"),
 }
 
+// endOfInput is the character past the end of the input. It is not a valid
+// rune, so that a NUL character in the input is lexed like any other.
+const endOfInput rune = -1
+
 type Tokenizer struct {
 	position     int
 	readPosition int
 	ch           rune
 	input        string
+	done         bool
 }
 
+var _ Iterator = (*Tokenizer)(nil)
+
 func NewTokenizer(input string) Tokenizer {
 	var tokenizer = Tokenizer{
 		position:     0,
@@ -100,10 +194,38 @@ func (tokenizer *Tokenizer) GetNextToken() Token {
 	case '+':
 		tok = CreateToken(Plus, string(tokenizer.ch))
 		tokNil = false
+	case '-':
+		tok = CreateToken(Minus, string(tokenizer.ch))
+		tokNil = false
+	case '*':
+		tok = CreateToken(Asterisk, string(tokenizer.ch))
+		tokNil = false
+	case '/':
+		tok = CreateToken(Slash, string(tokenizer.ch))
+		tokNil = false
+	case '<':
+		tok = CreateToken(LessThan, string(tokenizer.ch))
+		tokNil = false
+	case '>':
+		tok = CreateToken(GreaterThan, string(tokenizer.ch))
+		tokNil = false
 	case '=':
-		tok = CreateToken(Equal, string(tokenizer.ch))
+		if tokenizer.peekChar() == '=' {
+			tokenizer.readChar()
+			tok = CreateToken(Eq, "==")
+		} else {
+			tok = CreateToken(Equal, string(tokenizer.ch))
+		}
 		tokNil = false
-	case '\x00':
+	case '!':
+		if tokenizer.peekChar() == '=' {
+			tokenizer.readChar()
+			tok = CreateToken(NotEq, "!=")
+		} else {
+			tok = CreateToken(Bang, string(tokenizer.ch))
+		}
+		tokNil = false
+	case endOfInput:
 		tok = CreateToken(Eof, "eof")
 		tokNil = false
 	}
@@ -119,26 +241,47 @@ func (tokenizer *Tokenizer) GetNextToken() Token {
 	} else if isNumber((tokenizer.ch)) {
 		return CreateToken(Int, tokenizer.readInt())
 	} else if tokNil {
-		return CreateToken(Illegal, string(tokenizer.ch))
+		tok = CreateToken(Illegal, tokenizer.input[tokenizer.position:tokenizer.readPosition])
 	}
 
 	tokenizer.readChar()
 	return tok
 }
 
+// Next returns the next token, or false once the Eof token has been
+// returned.
+func (tokenizer *Tokenizer) Next() (Token, bool) {
+	if tokenizer.done {
+		return Token{}, false
+	}
+
+	var tok = tokenizer.GetNextToken()
+	tokenizer.done = tok.type_ == Eof
+	return tok, true
+}
+
 func (tokenizer *Tokenizer) readChar() {
+	var width = 1
 	if tokenizer.readPosition >= len(tokenizer.input) {
-		tokenizer.ch = '\x00'
+		tokenizer.ch = endOfInput
 	} else {
-		tokenizer.ch = rune(tokenizer.input[tokenizer.readPosition])
+		tokenizer.ch, width = utf8.DecodeRuneInString(tokenizer.input[tokenizer.readPosition:])
 	}
 
 	tokenizer.position = tokenizer.readPosition
-	tokenizer.readPosition++
+	tokenizer.readPosition += width
+}
+
+func (tokenizer *Tokenizer) peekChar() rune {
+	if tokenizer.readPosition >= len(tokenizer.input) {
+		return endOfInput
+	}
+	var char, _ = utf8.DecodeRuneInString(tokenizer.input[tokenizer.readPosition:])
+	return char
 }
 
 func (tokenizer *Tokenizer) skipWhitespace() {
-	for tokenizer.ch == ' ' || tokenizer.ch == '\t' || tokenizer.ch == '\n' || tokenizer.ch == '\r' {
+	for isWhitespace(tokenizer.ch) {
 		tokenizer.readChar()
 	}
 }
@@ -146,7 +289,7 @@ func (tokenizer *Tokenizer) skipWhitespace() {
 func (tokenizer *Tokenizer) readIdent() string {
 	var position = tokenizer.position
 
-	for isLetter(tokenizer.ch) {
+	for isLetterOrDigit(tokenizer.ch) {
 		tokenizer.readChar()
 	}
 